@@ -1,6 +1,10 @@
 package filepath
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/patrickhuber/go-cross/platform"
+)
 
 // Comparison operation determines how paths are compared. IgnoreCase or CaseSensitive
 type Comparison interface {
@@ -28,3 +32,13 @@ func (cmp comparison) Equal(s, t string) bool {
 	}
 	return false
 }
+
+// ComparisonFromPlatform returns the default case sensitivity of file systems on the platform.
+// Windows (NTFS) and Darwin (APFS) are case insensitive by default
+func ComparisonFromPlatform(plat platform.Platform) Comparison {
+	switch plat {
+	case platform.Windows, platform.Darwin, platform.IOS:
+		return IgnoreCase
+	}
+	return CaseSensitive
+}
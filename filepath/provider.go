@@ -46,14 +46,13 @@ func NewProviderFromOS(o os.OS) Provider {
 	parser := NewParserFromPlatform(plat)
 
 	p := &provider{
-		parser: parser,
-		os:     o,
+		parser:     parser,
+		os:         o,
+		comparison: ComparisonFromPlatform(plat),
 	}
 	if platform.IsWindows(plat) {
-		p.comparison = IgnoreCase
 		p.separator = BackwardSlash
 	} else {
-		p.comparison = CaseSensitive
 		p.separator = ForwardSlash
 	}
 	return p
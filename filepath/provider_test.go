@@ -376,6 +376,25 @@ func TestExt(t *testing.T) {
 	}
 }
 
+func TestComparison(t *testing.T) {
+	type test struct {
+		platform platform.Platform
+		expected filepath.Comparison
+	}
+	tests := []test{
+		{platform.Linux, filepath.CaseSensitive},
+		{platform.FreeBSD, filepath.CaseSensitive},
+		{platform.Darwin, filepath.IgnoreCase},
+		{platform.Windows, filepath.IgnoreCase},
+	}
+	for _, test := range tests {
+		provider := filepath.NewProviderFromOS(
+			os.NewMemory(
+				os.WithPlatform(test.platform)))
+		require.Equal(t, test.expected, provider.Comparison(), test.platform.String())
+	}
+}
+
 func TestBase(t *testing.T) {
 	type test struct {
 		path     string
@@ -0,0 +1,448 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+// ExtractTar extracts the tar archive read from r into dst.Dir. Modes, modification times, symbolic links and hard
+// links are preserved. Entries whose name or link target would land outside dst.Dir, and entries written through a
+// symbolic link, fail with an error wrapping ErrPathEscapes.
+func ExtractTar(r io.Reader, dst Tree) error {
+	x, err := newExtractor(dst)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		// insecure names are reported by the reader when enabled with GODEBUG and are checked below instead
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			return err
+		}
+		mode := header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(header.Name, mode, header.ModTime)
+		case tar.TypeReg, tar.TypeChar, tar.TypeBlock, tar.TypeFifo, tar.TypeCont:
+			if header.Typeflag != tar.TypeReg {
+				// devices and pipes can't be represented so they are skipped
+				continue
+			}
+			err = x.file(header.Name, mode, header.ModTime, tr)
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			err = x.link(header.Name, header.Linkname)
+		default:
+			// extended headers are consumed by the reader, other types are skipped
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return x.finish()
+}
+
+// ExtractTarGzip extracts the gzip compressed tar archive read from r into dst.Dir. See ExtractTar
+func ExtractTarGzip(r io.Reader, dst Tree) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	return ExtractTar(gr, dst)
+}
+
+// ExtractZip extracts the zip archive read from r into dst.Dir. Modes, modification times and symbolic links are
+// preserved. Entries whose name or link target would land outside dst.Dir, and entries written through a symbolic
+// link, fail with an error wrapping ErrPathEscapes.
+func ExtractZip(r io.ReaderAt, size int64, dst Tree) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return err
+	}
+	x, err := newExtractor(dst)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if err := extractZipFile(x, f); err != nil {
+			return err
+		}
+	}
+	return x.finish()
+}
+
+func extractZipFile(x *extractor, f *zip.File) error {
+	mode := f.Mode()
+	switch {
+	case mode.IsDir():
+		return x.dir(f.Name, mode, f.Modified)
+	case mode&iofs.ModeSymlink != 0:
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		target, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		return x.symlink(f.Name, string(target))
+	case mode.IsRegular():
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return x.file(f.Name, mode, f.Modified, rc)
+	}
+	return nil
+}
+
+// extractor writes archive entries under the root of the destination tree
+type extractor struct {
+	dst Tree
+	// dirs are the directories extracted from the archive. Their mode and times are applied once all entries are
+	// written so read only directories can still be filled and adding children doesn't change their times
+	dirs []extractedDir
+}
+
+type extractedDir struct {
+	name    string
+	mode    iofs.FileMode
+	modTime time.Time
+}
+
+func newExtractor(dst Tree) (*extractor, error) {
+	if err := dst.FS.MkdirAll(dst.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return &extractor{dst: dst}, nil
+}
+
+// target translates the slash separated archive name to a path under the root. The path is rejected if it is outside
+// the root or any of its parent directories under the root is a symbolic link
+func (x *extractor) target(name string) (string, error) {
+	elements := append([]string{x.dst.Dir}, strings.Split(name, "/")...)
+	target := x.dst.Path.Join(elements...)
+	segments, err := x.inside(target)
+	if err != nil {
+		return "", &iofs.PathError{Op: "extract", Path: name, Err: err}
+	}
+
+	current := x.dst.Dir
+	for _, segment := range segments[:max(len(segments)-1, 0)] {
+		current = x.dst.Path.Join(current, segment)
+		info, err := x.dst.FS.Lstat(current)
+		if err != nil {
+			// missing parents are created later
+			break
+		}
+		if info.Mode()&iofs.ModeSymlink != 0 {
+			return "", &iofs.PathError{Op: "extract", Path: name, Err: ErrPathEscapes}
+		}
+	}
+	return target, nil
+}
+
+// inside returns the segments of target relative to the root or ErrPathEscapes if target is outside the root
+func (x *extractor) inside(target string) ([]string, error) {
+	rel, err := x.dst.Path.Rel(x.dst.Dir, target)
+	if err != nil {
+		return nil, ErrPathEscapes
+	}
+	fp, err := x.dst.Path.Parse(rel)
+	if err != nil {
+		return nil, err
+	}
+	if fp.IsAbs() || (len(fp.Segments) > 0 && fp.Segments[0] == filepath.ParentDirectory) {
+		return nil, ErrPathEscapes
+	}
+	var segments []string
+	for _, segment := range fp.Segments {
+		if segment != filepath.CurrentDirectory && segment != filepath.EmptyDirectory {
+			segments = append(segments, segment)
+		}
+	}
+	return segments, nil
+}
+
+// prepare creates the parent directory of target and removes an existing symbolic link at target so it is replaced
+// instead of written through
+func (x *extractor) prepare(target string) error {
+	if err := x.dst.FS.MkdirAll(x.dst.Path.Dir(target), 0755); err != nil {
+		return err
+	}
+	info, err := x.dst.FS.Lstat(target)
+	if err == nil && info.Mode()&iofs.ModeSymlink != 0 {
+		return x.dst.FS.Remove(target)
+	}
+	return nil
+}
+
+func (x *extractor) dir(name string, mode iofs.FileMode, modTime time.Time) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	if err := x.dst.FS.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	x.dirs = append(x.dirs, extractedDir{name: target, mode: mode.Perm(), modTime: modTime})
+	return nil
+}
+
+func (x *extractor) file(name string, mode iofs.FileMode, modTime time.Time, r io.Reader) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	f, err := x.dst.FS.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// the create mode is subject to umask and existing files keep their mode so set it explicitly
+	if err := x.dst.FS.Chmod(target, mode.Perm()); err != nil {
+		return err
+	}
+	return x.dst.FS.Chtimes(target, modTime, modTime)
+}
+
+// symlink creates a link after checking the link target resolves inside the root. Targets use forward slashes in the
+// archive and are translated with the path provider
+func (x *extractor) symlink(name string, linkname string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+
+	oldname := linkname
+	fp, err := x.dst.Path.Parse(linkname)
+	if err != nil {
+		return &iofs.PathError{Op: "extract", Path: name, Err: err}
+	}
+	if x.dst.Path.VolumeName(linkname) != "" && !fp.IsAbs() {
+		// a volume relative target like d:name is relative to the working directory of another volume
+		return &iofs.PathError{Op: "extract", Path: name, Err: fmt.Errorf("link target %q: %w", linkname, ErrPathEscapes)}
+	}
+	resolved := linkname
+	if !fp.IsAbs() {
+		oldname = x.dst.Path.Join(strings.Split(linkname, "/")...)
+		resolved = x.dst.Path.Join(x.dst.Path.Dir(target), oldname)
+	}
+	if _, err := x.inside(resolved); err != nil {
+		return &iofs.PathError{Op: "extract", Path: name, Err: fmt.Errorf("link target %q: %w", linkname, err)}
+	}
+
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	if _, err := x.dst.FS.Lstat(target); err == nil {
+		if err := x.dst.FS.Remove(target); err != nil {
+			return err
+		}
+	}
+	return x.dst.FS.Symlink(oldname, target)
+}
+
+// link creates a hard link to an entry extracted earlier
+func (x *extractor) link(name string, linkname string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	oldname, err := x.target(linkname)
+	if err != nil {
+		return err
+	}
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	if _, err := x.dst.FS.Lstat(target); err == nil {
+		if err := x.dst.FS.Remove(target); err != nil {
+			return err
+		}
+	}
+	return x.dst.FS.Link(oldname, target)
+}
+
+// finish applies the mode and times of extracted directories deepest first
+func (x *extractor) finish() error {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		dir := x.dirs[i]
+		if err := x.dst.FS.Chmod(dir.name, dir.mode); err != nil {
+			return err
+		}
+		if err := x.dst.FS.Chtimes(dir.name, dir.modTime, dir.modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateTar writes the contents of src.Dir to w as a tar archive. Entry names are relative to src.Dir and use forward
+// slashes. Modes, modification times, ownership and symbolic links are preserved.
+func CreateTar(w io.Writer, src Tree) error {
+	tw := tar.NewWriter(w)
+	err := walkArchive(src, func(name string, path string, info iofs.FileInfo, link string) error {
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if stat, ok := info.Sys().(*MemoryStat); ok {
+			header.Uid = stat.Uid
+			header.Gid = stat.Gid
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyTo(tw, src.FS, path)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// CreateTarGzip writes the contents of src.Dir to w as a gzip compressed tar archive. See CreateTar
+func CreateTarGzip(w io.Writer, src Tree) error {
+	gw := gzip.NewWriter(w)
+	if err := CreateTar(gw, src); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// CreateZip writes the contents of src.Dir to w as a zip archive. Entry names are relative to src.Dir and use forward
+// slashes. Modes, modification times and symbolic links are preserved.
+func CreateZip(w io.Writer, src Tree) error {
+	zw := zip.NewWriter(w)
+	err := walkArchive(src, func(name string, path string, info iofs.FileInfo, link string) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		} else if info.Mode().IsRegular() {
+			header.Method = zip.Deflate
+		}
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&iofs.ModeSymlink != 0:
+			_, err = io.WriteString(entry, link)
+			return err
+		case info.Mode().IsRegular():
+			return copyTo(entry, src.FS, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// walkArchive calls fn for every entry below src.Dir with the slash separated archive name, the path in the file
+// system, the entry info and the slash separated target of symbolic links
+func walkArchive(src Tree, fn func(name string, path string, info iofs.FileInfo, link string) error) error {
+	return WalkDir(src, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := src.Path.Rel(src.Dir, path)
+		if err != nil {
+			return err
+		}
+		name, err := slashPath(src.Path, rel)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			// the root itself is not part of the archive
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&iofs.ModeSymlink != 0 {
+			target, err := src.FS.Readlink(path)
+			if err != nil {
+				return err
+			}
+			link = target
+			if fp, err := src.Path.Parse(target); err == nil && fp.IsRel() {
+				link = strings.Join(fp.Segments, "/")
+			}
+		}
+		return fn(name, path, info, link)
+	})
+}
+
+// slashPath converts a relative path to forward slashes. The current directory is returned as an empty string
+func slashPath(fp filepath.Provider, rel string) (string, error) {
+	parsed, err := fp.Parse(rel)
+	if err != nil {
+		return "", err
+	}
+	var segments []string
+	for _, segment := range parsed.Clean().Segments {
+		if segment != filepath.CurrentDirectory && segment != filepath.EmptyDirectory {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// copyTo copies the content of the named file to w
+func copyTo(w io.Writer, fsys FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,248 @@
+package fs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	iofs "io/fs"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveFormat struct {
+	name    string
+	create  func(*bytes.Buffer, fs.Tree) error
+	extract func([]byte, fs.Tree) error
+}
+
+func archiveFormats() []archiveFormat {
+	return []archiveFormat{
+		{
+			"tar",
+			func(b *bytes.Buffer, src fs.Tree) error { return fs.CreateTar(b, src) },
+			func(data []byte, dst fs.Tree) error { return fs.ExtractTar(bytes.NewReader(data), dst) },
+		},
+		{
+			"tar_gzip",
+			func(b *bytes.Buffer, src fs.Tree) error { return fs.CreateTarGzip(b, src) },
+			func(data []byte, dst fs.Tree) error { return fs.ExtractTarGzip(bytes.NewReader(data), dst) },
+		},
+		{
+			"zip",
+			func(b *bytes.Buffer, src fs.Tree) error { return fs.CreateZip(b, src) },
+			func(data []byte, dst fs.Tree) error {
+				return fs.ExtractZip(bytes.NewReader(data), int64(len(data)), dst)
+			},
+		},
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, format := range archiveFormats() {
+		t.Run(format.name, func(t *testing.T) {
+			srcFS, srcPath := newMemory(newOS(platform.Linux))
+			src := fs.Tree{FS: srcFS, Path: srcPath, Dir: "/release"}
+			modTime := setupTree(t, srcFS, srcPath, src.Dir)
+			require.NoError(t, srcFS.Symlink("../one.txt", "/release/sub/up.txt"))
+			require.NoError(t, srcFS.Mkdir("/release/private", 0700))
+
+			var buf bytes.Buffer
+			require.NoError(t, format.create(&buf, src))
+
+			dstFS, dstPath := newMemory(newOS(platform.Windows))
+			dst := fs.Tree{FS: dstFS, Path: dstPath, Dir: `c:\working`}
+			require.NoError(t, format.extract(buf.Bytes(), dst))
+
+			content, err := dstFS.ReadFile(`c:\working\sub\two.sh`)
+			require.NoError(t, err)
+			require.Equal(t, []byte("two"), content)
+
+			info, err := dstFS.Stat(`c:\working\sub\two.sh`)
+			require.NoError(t, err)
+			require.Equal(t, iofs.FileMode(0755), info.Mode().Perm())
+
+			info, err = dstFS.Stat(`c:\working\one.txt`)
+			require.NoError(t, err)
+			require.True(t, modTime.Equal(info.ModTime()), "expected %v got %v", modTime, info.ModTime())
+
+			info, err = dstFS.Stat(`c:\working\private`)
+			require.NoError(t, err)
+			require.True(t, info.IsDir())
+			require.Equal(t, iofs.FileMode(0700), info.Mode().Perm())
+
+			// link targets are translated to the destination separators
+			target, err := dstFS.Readlink(`c:\working\sub\up.txt`)
+			require.NoError(t, err)
+			require.Equal(t, `..\one.txt`, target)
+			content, err = dstFS.ReadFile(`c:\working\link.txt`)
+			require.NoError(t, err)
+			require.Equal(t, []byte("one"), content)
+		})
+	}
+}
+
+func TestArchiveWithOS(t *testing.T) {
+	path := filepath.NewProviderFromOS(os.New())
+	srcFS := fs.New()
+	src := fs.Tree{FS: srcFS, Path: path, Dir: t.TempDir()}
+	require.NoError(t, srcFS.MkdirAll(path.Join(src.Dir, "sub"), 0755))
+	require.NoError(t, srcFS.WriteFile(path.Join(src.Dir, "sub", "file.txt"), []byte("file"), 0644))
+
+	for _, format := range archiveFormats() {
+		t.Run(format.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, format.create(&buf, src))
+
+			dst := fs.Tree{FS: fs.New(), Path: path, Dir: path.Join(t.TempDir(), "out")}
+			require.NoError(t, format.extract(buf.Bytes(), dst))
+
+			content, err := dst.FS.ReadFile(path.Join(dst.Dir, "sub", "file.txt"))
+			require.NoError(t, err)
+			require.Equal(t, []byte("file"), content)
+		})
+	}
+}
+
+type archiveEntry struct {
+	name string
+	link string
+	kind byte
+}
+
+func newTar(t *testing.T, entries ...archiveEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Linkname: entry.link, Typeflag: entry.kind, Mode: 0644, ModTime: time.Unix(0, 0)}
+		data := []byte("data")
+		if entry.kind == tar.TypeReg {
+			header.Size = int64(len(data))
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		if entry.kind == tar.TypeReg {
+			_, err := tw.Write(data)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func newZip(t *testing.T, entries ...archiveEntry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name}
+		data := []byte("data")
+		if entry.kind == tar.TypeSymlink {
+			header.SetMode(iofs.ModeSymlink | 0777)
+			data = []byte(entry.link)
+		}
+		w, err := zw.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestArchivePathTraversal(t *testing.T) {
+	type test struct {
+		name     string
+		platform platform.Platform
+		root     string
+		tar      []archiveEntry
+		zip      []archiveEntry
+	}
+	tests := []test{
+		{name: "parent", platform: platform.Linux, root: "/working", tar: []archiveEntry{{name: "../evil.txt", kind: tar.TypeReg}}},
+		{name: "nested_parent", platform: platform.Linux, root: "/working", tar: []archiveEntry{{name: "dir/../../evil.txt", kind: tar.TypeReg}}},
+		{name: "windows_parent", platform: platform.Windows, root: `c:\working`, tar: []archiveEntry{{name: `..\evil.txt`, kind: tar.TypeReg}}},
+		{name: "symlink_target", platform: platform.Linux, root: "/working", tar: []archiveEntry{{name: "link", link: "../evil.txt", kind: tar.TypeSymlink}}},
+		{name: "absolute_symlink_target", platform: platform.Linux, root: "/working", tar: []archiveEntry{{name: "link", link: "/evil.txt", kind: tar.TypeSymlink}}},
+		{name: "hard_link_target", platform: platform.Linux, root: "/working", tar: []archiveEntry{{name: "link", link: "../evil.txt", kind: tar.TypeLink}}},
+		{name: "through_symlink", platform: platform.Linux, root: "/working", tar: []archiveEntry{
+			{name: "dir", link: ".", kind: tar.TypeSymlink},
+			{name: "dir/link", link: "../evil.txt", kind: tar.TypeSymlink},
+		}},
+		{name: "volume_relative_symlink_target", platform: platform.Windows, root: `c:\working`, tar: []archiveEntry{{name: "link", link: "d:evil.txt", kind: tar.TypeSymlink}}},
+		{name: "other_volume_symlink_target", platform: platform.Windows, root: `c:\working`, tar: []archiveEntry{{name: "link", link: `d:\working\evil.txt`, kind: tar.TypeSymlink}}},
+		{name: "zip_parent", platform: platform.Linux, root: "/working", zip: []archiveEntry{{name: "../evil.txt", kind: tar.TypeReg}}},
+		{name: "zip_windows_parent", platform: platform.Windows, root: `c:\working`, zip: []archiveEntry{{name: `sub\..\..\evil.txt`, kind: tar.TypeReg}}},
+		{name: "zip_volume_relative_symlink_target", platform: platform.Windows, root: `c:\working`, zip: []archiveEntry{{name: "link", link: "d:evil.txt", kind: tar.TypeSymlink}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys, path := newMemory(newOS(test.platform))
+			dst := fs.Tree{FS: fsys, Path: path, Dir: test.root}
+
+			var err error
+			if test.zip != nil {
+				data := newZip(t, test.zip...)
+				err = fs.ExtractZip(bytes.NewReader(data), int64(len(data)), dst)
+			} else {
+				err = fs.ExtractTar(bytes.NewReader(newTar(t, test.tar...)), dst)
+			}
+			require.ErrorIs(t, err, fs.ErrPathEscapes)
+
+			ok, err := fsys.Exists(path.Join(path.Dir(test.root), "evil.txt"))
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestArchiveLinksInsideRoot(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Linux))
+	dst := fs.Tree{FS: fsys, Path: path, Dir: "/working"}
+
+	data := newTar(t,
+		archiveEntry{name: "dir/file.txt", kind: tar.TypeReg},
+		archiveEntry{name: "dir/link.txt", link: "file.txt", kind: tar.TypeSymlink},
+		archiveEntry{name: "hard.txt", link: "dir/file.txt", kind: tar.TypeLink},
+		archiveEntry{name: "absolute", link: "/working/dir", kind: tar.TypeSymlink},
+	)
+	require.NoError(t, fs.ExtractTar(bytes.NewReader(data), dst))
+
+	content, err := fsys.ReadFile("/working/dir/link.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), content)
+	content, err = fsys.ReadFile("/working/hard.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), content)
+	content, err = fsys.ReadFile("/working/absolute/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), content)
+}
+
+func TestArchiveReplacesExistingLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires elevated privileges on windows")
+	}
+	path := filepath.NewProviderFromOS(os.New())
+	fsys := fs.New()
+	dir := t.TempDir()
+	root := path.Join(dir, "out")
+	require.NoError(t, fsys.Mkdir(root, 0755))
+	require.NoError(t, fsys.WriteFile(path.Join(dir, "outside.txt"), []byte("outside"), 0644))
+	require.NoError(t, fsys.Symlink(path.Join(dir, "outside.txt"), path.Join(root, "file.txt")))
+
+	// an existing link in the destination is replaced instead of written through
+	data := newTar(t, archiveEntry{name: "file.txt", kind: tar.TypeReg})
+	require.NoError(t, fs.ExtractTar(bytes.NewReader(data), fs.Tree{FS: fsys, Path: path, Dir: root}))
+
+	content, err := fsys.ReadFile(path.Join(dir, "outside.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("outside"), content)
+	content, err = fsys.ReadFile(path.Join(root, "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), content)
+}
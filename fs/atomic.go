@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// WriteFileAtomic writes data to a temporary file next to name and then renames it over name. Readers
+// observe either the previous content or the new content, never a partially written file. If name
+// already exists its permissions are preserved, otherwise perm is used. The temporary file is removed
+// if any step fails.
+func WriteFileAtomic(fsys FS, name string, data []byte, perm iofs.FileMode) (err error) {
+
+	// preserve the permissions of an existing file
+	info, err := fsys.Stat(name)
+	switch {
+	case err == nil:
+		perm = info.Mode().Perm()
+	case !errors.Is(err, iofs.ErrNotExist):
+		return err
+	}
+
+	f, temp, err := createSibling(fsys, name, perm)
+	if err != nil {
+		return err
+	}
+
+	// clean up the temporary file on failure
+	closed := false
+	defer func() {
+		if err == nil {
+			return
+		}
+		if !closed {
+			f.Close()
+		}
+		fsys.Remove(temp)
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+
+	// flush to stable storage when the file supports it
+	if syncer, ok := f.(interface{ Sync() error }); ok {
+		if err = syncer.Sync(); err != nil {
+			return err
+		}
+	}
+
+	closed = true
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	// the create mode is subject to umask so set the permissions explicitly
+	if err = fsys.Chmod(temp, perm); err != nil {
+		return err
+	}
+
+	return fsys.Rename(temp, name)
+}
+
+// createSibling exclusively creates a uniquely named temporary file in the same directory as name
+func createSibling(fsys FS, name string, perm iofs.FileMode) (File, string, error) {
+	for try := 0; try < 10000; try++ {
+		temp := name + ".tmp" + strconv.FormatUint(uint64(rand.Uint32()), 10)
+		ok, err := fsys.Exists(temp)
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			continue
+		}
+		f, err := fsys.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, iofs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return f, temp, nil
+	}
+	return nil, "", &iofs.PathError{Op: "open", Path: name + ".tmp*", Err: iofs.ErrExist}
+}
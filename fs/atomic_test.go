@@ -0,0 +1,126 @@
+package fs_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+type failRename struct {
+	fs.FS
+}
+
+func (f *failRename) Rename(oldName, newName string) error {
+	return errors.New("rename failed")
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	type test struct {
+		name   string
+		fsys   fs.FS
+		path   filepath.Provider
+		folder string
+	}
+	memoryFS, memoryPath := newMemory(newOS(platform.Linux))
+	windowsFS, windowsPath := newMemory(newOS(platform.Windows))
+	tests := []test{
+		{"memory", memoryFS, memoryPath, "/gran/parent/child"},
+		{"windows", windowsFS, windowsPath, `c:\gran\parent\child`},
+		{"os", fs.New(), filepath.NewProviderFromOS(os.New()), t.TempDir()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, test.fsys.MkdirAll(test.folder, 0775))
+			name := test.path.Join(test.folder, "config.yaml")
+
+			require.NoError(t, fs.WriteFileAtomic(test.fsys, name, []byte("first"), 0600))
+			content, err := test.fsys.ReadFile(name)
+			require.NoError(t, err)
+			require.Equal(t, []byte("first"), content)
+
+			// existing permissions are preserved
+			require.NoError(t, test.fsys.Chmod(name, 0640))
+			require.NoError(t, fs.WriteFileAtomic(test.fsys, name, []byte("second"), 0600))
+
+			content, err = test.fsys.ReadFile(name)
+			require.NoError(t, err)
+			require.Equal(t, []byte("second"), content)
+
+			info, err := test.fsys.Stat(name)
+			require.NoError(t, err)
+			require.Equal(t, 0640, int(info.Mode().Perm()))
+
+			// no temporary files are left behind
+			entries, err := test.fsys.ReadDir(test.folder)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestWriteFileAtomicCleansUpOnFailure(t *testing.T) {
+	fsys, _ := newMemory(newOS(platform.Linux))
+	require.NoError(t, fsys.MkdirAll("/opt/app", 0775))
+	require.NoError(t, fsys.WriteFile("/opt/app/config.yaml", []byte("original"), 0644))
+
+	err := fs.WriteFileAtomic(&failRename{FS: fsys}, "/opt/app/config.yaml", []byte("updated"), 0644)
+	require.Error(t, err)
+
+	content, err := fsys.ReadFile("/opt/app/config.yaml")
+	require.NoError(t, err)
+	require.Equal(t, []byte("original"), content)
+
+	entries, err := fsys.ReadDir("/opt/app")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestWriteFileAtomicConcurrentReaders(t *testing.T) {
+	fsys, _ := newMemory(newOS(platform.Linux))
+	require.NoError(t, fsys.MkdirAll("/opt/app", 0775))
+
+	first := []byte("first version of the config")
+	second := []byte("second version of the config which is longer")
+	require.NoError(t, fsys.WriteFile("/opt/app/config.yaml", first, 0644))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				content, err := fsys.ReadFile("/opt/app/config.yaml")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if string(content) != string(first) && string(content) != string(second) {
+					t.Errorf("read partial content %q", content)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		data := first
+		if i%2 == 0 {
+			data = second
+		}
+		require.NoError(t, fs.WriteFileAtomic(fsys, "/opt/app/config.yaml", data, 0644))
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,533 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+// ErrPathEscapes is returned when a path or symbolic link resolves outside the root of a base path file system
+var ErrPathEscapes = errors.New("path escapes from root")
+
+type basePath struct {
+	fs   FS
+	path filepath.Provider
+	root string
+	// valid requires names to be valid io/fs paths, it is set for file systems returned from Sub
+	valid bool
+}
+
+// NewBasePath creates a file system confined to the root directory of fsys. Relative paths are resolved from
+// the root and absolute paths must be inside the root. Symbolic links are resolved one segment at a time so
+// neither .. segments nor link targets can escape the root.
+func NewBasePath(fsys FS, path filepath.Provider, root string) FS {
+	return &basePath{
+		fs:   fsys,
+		path: path,
+		root: path.Clean(root),
+	}
+}
+
+// Open implements FS
+func (b *basePath) Open(name string) (iofs.File, error) {
+	real, err := b.realPath("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fs.Open(real)
+	if err != nil {
+		return nil, b.restore(err, name)
+	}
+	if file, ok := f.(File); ok {
+		return &basePathFile{File: file, name: name}, nil
+	}
+	return f, nil
+}
+
+// OpenFile implements FS
+func (b *basePath) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	real, err := b.realPath("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fs.OpenFile(real, flag, perm)
+	if err != nil {
+		return nil, b.restore(err, name)
+	}
+	return &basePathFile{File: f, name: name}, nil
+}
+
+// Create implements FS
+func (b *basePath) Create(name string) (File, error) {
+	real, err := b.realPath("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fs.Create(real)
+	if err != nil {
+		return nil, b.restore(err, name)
+	}
+	return &basePathFile{File: f, name: name}, nil
+}
+
+// Rename implements FS
+func (b *basePath) Rename(oldName, newName string) error {
+	oldReal, err := b.realPath("rename", oldName, false)
+	if err != nil {
+		return err
+	}
+	newReal, err := b.realPath("rename", newName, false)
+	if err != nil {
+		return err
+	}
+	return b.restoreLink(b.fs.Rename(oldReal, newReal), oldName, newName)
+}
+
+// Remove implements FS
+func (b *basePath) Remove(name string) error {
+	real, err := b.realPath("remove", name, false)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.Remove(real), name)
+}
+
+// RemoveAll implements FS
+func (b *basePath) RemoveAll(name string) error {
+	real, err := b.realPath("removeall", name, false)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.RemoveAll(real), name)
+}
+
+// Glob implements FS. File systems returned from Sub match patterns like io/fs.Glob
+func (b *basePath) Glob(pattern string) ([]string, error) {
+	if b.valid {
+		return globPaths(b, pattern)
+	}
+	return globFS(b, b.path, pattern)
+}
+
+// ReadDir implements FS
+func (b *basePath) ReadDir(name string) ([]iofs.DirEntry, error) {
+	real, err := b.realPath("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := b.fs.ReadDir(real)
+	return entries, b.restore(err, name)
+}
+
+// ReadFile implements FS
+func (b *basePath) ReadFile(name string) ([]byte, error) {
+	real, err := b.realPath("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.fs.ReadFile(real)
+	return data, b.restore(err, name)
+}
+
+// WriteFile implements FS
+func (b *basePath) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	real, err := b.realPath("open", name, true)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.WriteFile(real, data, perm), name)
+}
+
+// Exists implements FS
+func (b *basePath) Exists(name string) (bool, error) {
+	real, err := b.realPath("stat", name, true)
+	if err != nil {
+		return false, err
+	}
+	ok, err := b.fs.Exists(real)
+	return ok, b.restore(err, name)
+}
+
+// Stat implements FS
+func (b *basePath) Stat(name string) (iofs.FileInfo, error) {
+	real, err := b.realPath("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := b.fs.Stat(real)
+	return info, b.restore(err, name)
+}
+
+// Sub implements FS. The returned file system is confined to the sub directory
+func (b *basePath) Sub(dir string) (iofs.FS, error) {
+	real, err := b.realPath("sub", dir, true)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := subDir(b.fs, b.path, real)
+	return sub, b.restore(err, dir)
+}
+
+// subDir returns a base path file system rooted at dir after checking that dir is a directory. Like io/fs.Sub the
+// names passed to the file system must be valid io/fs paths
+func subDir(fsys FS, path filepath.Provider, dir string) (FS, error) {
+	info, err := fsys.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &iofs.PathError{Op: "sub", Path: dir, Err: syscall.ENOTDIR}
+	}
+	return &basePath{
+		fs:    fsys,
+		path:  path,
+		root:  path.Clean(dir),
+		valid: true,
+	}, nil
+}
+
+// Mkdir implements MakeDirFS
+func (b *basePath) Mkdir(name string, perm iofs.FileMode) error {
+	real, err := b.realPath("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.Mkdir(real, perm), name)
+}
+
+// MkdirAll implements MakeDirFS
+func (b *basePath) MkdirAll(name string, perm iofs.FileMode) error {
+	real, err := b.realPath("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.MkdirAll(real, perm), name)
+}
+
+// Chmod implements ChmodFS
+func (b *basePath) Chmod(name string, mode iofs.FileMode) error {
+	real, err := b.realPath("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.Chmod(real, mode), name)
+}
+
+// Symlink implements SymlinkFS. The target is stored as is and checked against the root when the link is resolved
+func (b *basePath) Symlink(oldname, newname string) error {
+	real, err := b.realPath("symlink", newname, false)
+	if err != nil {
+		return err
+	}
+	return b.restoreLink(b.fs.Symlink(oldname, real), oldname, newname)
+}
+
+// Readlink implements SymlinkFS
+func (b *basePath) Readlink(name string) (string, error) {
+	real, err := b.realPath("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	target, err := b.fs.Readlink(real)
+	return target, b.restore(err, name)
+}
+
+// Lstat implements SymlinkFS
+func (b *basePath) Lstat(name string) (iofs.FileInfo, error) {
+	real, err := b.realPath("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := b.fs.Lstat(real)
+	return info, b.restore(err, name)
+}
+
+// Link implements LinkFS
+func (b *basePath) Link(oldname, newname string) error {
+	oldReal, err := b.realPath("link", oldname, false)
+	if err != nil {
+		return err
+	}
+	newReal, err := b.realPath("link", newname, false)
+	if err != nil {
+		return err
+	}
+	return b.restoreLink(b.fs.Link(oldReal, newReal), oldname, newname)
+}
+
+// Chtimes implements ChtimesFS
+func (b *basePath) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	real, err := b.realPath("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.Chtimes(real, atime, mtime), name)
+}
+
+// Chown implements ChownFS
+func (b *basePath) Chown(name string, uid, gid int) error {
+	real, err := b.realPath("chown", name, true)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.Chown(real, uid, gid), name)
+}
+
+// Lchown implements ChownFS
+func (b *basePath) Lchown(name string, uid, gid int) error {
+	real, err := b.realPath("lchown", name, false)
+	if err != nil {
+		return err
+	}
+	return b.restore(b.fs.Lchown(real, uid, gid), name)
+}
+
+// CreateTemp implements TempFS. An empty dir creates the file in the root
+func (b *basePath) CreateTemp(dir, pattern string) (File, error) {
+	if dir == "" {
+		dir = filepath.CurrentDirectory
+	}
+	real, err := b.realPath("createtemp", dir, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fs.CreateTemp(real, pattern)
+	if err != nil {
+		return nil, b.restore(err, dir)
+	}
+	return &basePathFile{File: f, name: b.name(f.Name(), dir)}, nil
+}
+
+// MkdirTemp implements TempFS. An empty dir creates the directory in the root
+func (b *basePath) MkdirTemp(dir, pattern string) (string, error) {
+	if dir == "" {
+		dir = filepath.CurrentDirectory
+	}
+	real, err := b.realPath("mkdirtemp", dir, true)
+	if err != nil {
+		return "", err
+	}
+	name, err := b.fs.MkdirTemp(real, pattern)
+	if err != nil {
+		return "", b.restore(err, dir)
+	}
+	return b.name(name, dir), nil
+}
+
+// Watch implements WatchFS. Event names are translated back to paths inside the root
+func (b *basePath) Watch(name string, recursive bool) (Watcher, error) {
+	real, err := b.realPath("watch", name, true)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := b.fs.Watch(real, recursive)
+	if err != nil {
+		return nil, b.restore(err, name)
+	}
+	return newMappedWatcher(inner, func(event Event) Event {
+		event.Name = b.name(event.Name, name)
+		return event
+	}), nil
+}
+
+// realPath maps name to a path in the underlying file system. Each segment is checked for symbolic links which are
+// resolved inside the root. When follow is false a symbolic link in the final segment is not resolved.
+func (b *basePath) realPath(op string, name string, follow bool) (string, error) {
+	if b.valid && !b.validPath(name) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	segments, err := b.segments(name)
+	if err != nil {
+		return "", &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	var current []string
+	links := 0
+	for len(segments) > 0 {
+		segment := segments[0]
+		segments = segments[1:]
+
+		switch segment {
+		case filepath.CurrentDirectory, filepath.EmptyDirectory:
+			continue
+		case filepath.ParentDirectory:
+			if len(current) == 0 {
+				return "", &iofs.PathError{Op: op, Path: name, Err: ErrPathEscapes}
+			}
+			current = current[:len(current)-1]
+			continue
+		}
+
+		next := append(current[:len(current):len(current)], segment)
+		if !follow && len(segments) == 0 {
+			current = next
+			break
+		}
+
+		info, err := b.fs.Lstat(b.join(next))
+		if err != nil || info.Mode()&iofs.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &iofs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+		}
+		target, err := b.fs.Readlink(b.join(next))
+		if err != nil {
+			return "", b.restore(err, name)
+		}
+
+		// absolute targets must be inside the root, relative targets resolve from the link's directory
+		fp, err := b.path.Parse(target)
+		if err != nil {
+			return "", &iofs.PathError{Op: op, Path: name, Err: err}
+		}
+		if fp.IsAbs() {
+			current = nil
+			targetSegments, err := b.segments(target)
+			if err != nil {
+				return "", &iofs.PathError{Op: op, Path: name, Err: err}
+			}
+			segments = append(targetSegments, segments...)
+		} else {
+			segments = append(append([]string{}, fp.Segments...), segments...)
+		}
+	}
+	return b.join(current), nil
+}
+
+// validPath reports whether name is a valid io/fs path. Like os.DirFS, backslashes and colons are rejected on
+// platforms that use them for separators and volumes so a name refers to the same file on every platform
+func (b *basePath) validPath(name string) bool {
+	if !iofs.ValidPath(name) {
+		return false
+	}
+	return b.path.Separator() != '\\' || !strings.ContainsAny(name, `\:`)
+}
+
+// segments returns the segments of name relative to the root
+func (b *basePath) segments(name string) ([]string, error) {
+	fp, err := b.path.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fp.IsAbs() {
+		return append([]string{}, fp.Segments...), nil
+	}
+	rel, err := b.path.Rel(b.root, name)
+	if err != nil {
+		return nil, ErrPathEscapes
+	}
+	relp, err := b.path.Parse(rel)
+	if err != nil {
+		return nil, err
+	}
+	if len(relp.Segments) > 0 && relp.Segments[0] == filepath.ParentDirectory {
+		return nil, ErrPathEscapes
+	}
+	return relp.Segments, nil
+}
+
+func (b *basePath) join(segments []string) string {
+	return b.path.Join(append([]string{b.root}, segments...)...)
+}
+
+// name translates a path in the underlying file system to a path in the root using the style of like.
+// Absolute paths keep their full path while relative paths are made relative to the root
+func (b *basePath) name(real string, like string) string {
+	fp, err := b.path.Parse(like)
+	if err == nil && fp.IsAbs() {
+		return real
+	}
+	rel, err := b.path.Rel(b.root, real)
+	if err != nil {
+		return real
+	}
+	return rel
+}
+
+// restore replaces the underlying path in path errors with the name used inside the root
+func (b *basePath) restore(err error, name string) error {
+	var perr *iofs.PathError
+	if errors.As(err, &perr) {
+		return &iofs.PathError{Op: perr.Op, Path: name, Err: perr.Err}
+	}
+	return err
+}
+
+// restoreLink replaces the underlying paths in link errors with the names used inside the root
+func (b *basePath) restoreLink(err error, oldname, newname string) error {
+	var lerr *os.LinkError
+	if errors.As(err, &lerr) {
+		return &os.LinkError{Op: lerr.Op, Old: oldname, New: newname, Err: lerr.Err}
+	}
+	return b.restore(err, newname)
+}
+
+// basePathFile reports the name used inside the root
+type basePathFile struct {
+	File
+	name string
+}
+
+func (f *basePathFile) Name() string {
+	return f.name
+}
+
+// ReadDir reads the entries of a directory. Errors report the name used inside the root instead of the real path
+func (f *basePathFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	entries, err := readDir(f.File, f.name, n)
+	var perr *iofs.PathError
+	if errors.As(err, &perr) {
+		err = &iofs.PathError{Op: perr.Op, Path: f.name, Err: perr.Err}
+	}
+	return entries, err
+}
+
+// mappedWatcher forwards events from a watcher after applying a mapping to each event
+type mappedWatcher struct {
+	inner  Watcher
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newMappedWatcher(inner Watcher, mapping func(Event) Event) Watcher {
+	w := &mappedWatcher{
+		inner:  inner,
+		events: make(chan Event, watchBufferSize),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(w.events)
+		for event := range inner.Events() {
+			select {
+			case w.events <- mapping(event):
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+func (w *mappedWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *mappedWatcher) Errors() <-chan error {
+	return w.inner.Errors()
+}
+
+func (w *mappedWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return w.inner.Close()
+}
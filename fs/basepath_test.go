@@ -0,0 +1,188 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	"runtime"
+	"testing"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func newBasePath(t *testing.T, plat platform.Platform, root string) (fs.FS, fs.FS, filepath.Provider) {
+	fsys, path := newMemory(newOS(plat))
+	require.NoError(t, fsys.MkdirAll(path.Join(root, "dir"), 0755))
+	require.NoError(t, fsys.WriteFile(path.Join(root, "dir", "file.txt"), []byte("inside"), 0644))
+	require.NoError(t, fsys.WriteFile(path.Join(path.Dir(root), "secret.txt"), []byte("outside"), 0644))
+	return fs.NewBasePath(fsys, path, root), fsys, path
+}
+
+func TestBasePathConfinesPaths(t *testing.T) {
+	type test struct {
+		name     string
+		platform platform.Platform
+		root     string
+		inside   []string
+		escapes  []string
+	}
+	tests := []test{
+		{
+			name:     "unix",
+			platform: platform.Linux,
+			root:     "/jail/root",
+			inside:   []string{"dir/file.txt", "./dir/../dir/file.txt", "/jail/root/dir/file.txt"},
+			escapes:  []string{"../secret.txt", "dir/../../secret.txt", "/jail/secret.txt"},
+		},
+		{
+			name:     "windows",
+			platform: platform.Windows,
+			root:     "c:\\jail\\root",
+			inside:   []string{"dir\\file.txt", "dir/file.txt", "c:\\jail\\root\\dir\\file.txt"},
+			escapes:  []string{"..\\secret.txt", "dir\\..\\..\\secret.txt", "c:\\jail\\secret.txt", "d:\\jail\\root\\dir\\file.txt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys, _, _ := newBasePath(t, test.platform, test.root)
+			for _, name := range test.inside {
+				content, err := fsys.ReadFile(name)
+				require.NoError(t, err, name)
+				require.Equal(t, []byte("inside"), content)
+			}
+			for _, name := range test.escapes {
+				_, err := fsys.ReadFile(name)
+				require.ErrorIs(t, err, fs.ErrPathEscapes, name)
+				err = fsys.WriteFile(name, []byte("changed"), 0644)
+				require.ErrorIs(t, err, fs.ErrPathEscapes, name)
+			}
+		})
+	}
+}
+
+func TestBasePathWritesUnderRoot(t *testing.T) {
+	fsys, inner, _ := newBasePath(t, platform.Linux, "/jail/root")
+
+	require.NoError(t, fsys.MkdirAll("new/sub", 0755))
+	require.NoError(t, fsys.WriteFile("new/sub/file.txt", []byte("new"), 0644))
+	require.NoError(t, fsys.Rename("new/sub/file.txt", "new/renamed.txt"))
+
+	content, err := inner.ReadFile("/jail/root/new/renamed.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), content)
+
+	f, err := fsys.Create("created.txt")
+	require.NoError(t, err)
+	require.Equal(t, "created.txt", f.Name())
+	require.NoError(t, f.Close())
+
+	// errors report the name used inside the root
+	_, err = fsys.Stat("missing.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	var perr *iofs.PathError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, "missing.txt", perr.Path)
+}
+
+func TestBasePathResolvesSymlinksInsideRoot(t *testing.T) {
+	fsys, inner, _ := newBasePath(t, platform.Linux, "/jail/root")
+
+	require.NoError(t, fsys.Symlink("dir/file.txt", "relative"))
+	require.NoError(t, fsys.Symlink("/jail/root/dir", "absolute"))
+	require.NoError(t, fsys.Symlink("../secret.txt", "escape"))
+	require.NoError(t, fsys.Symlink("/jail/secret.txt", "escape_absolute"))
+	require.NoError(t, fsys.Symlink("dir/../../secret.txt", "escape_nested"))
+	require.NoError(t, fsys.Symlink("escape", "escape_chain"))
+
+	content, err := fsys.ReadFile("relative")
+	require.NoError(t, err)
+	require.Equal(t, []byte("inside"), content)
+
+	content, err = fsys.ReadFile("absolute/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("inside"), content)
+
+	for _, name := range []string{"escape", "escape_absolute", "escape_nested", "escape_chain"} {
+		_, err = fsys.ReadFile(name)
+		require.ErrorIs(t, err, fs.ErrPathEscapes, name)
+
+		// writing through the link must not touch the file outside the root
+		err = fsys.WriteFile(name, []byte("changed"), 0644)
+		require.ErrorIs(t, err, fs.ErrPathEscapes, name)
+
+		// the link itself can still be inspected and removed
+		info, err := fsys.Lstat(name)
+		require.NoError(t, err)
+		require.Equal(t, iofs.ModeSymlink, info.Mode().Type())
+	}
+	require.NoError(t, fsys.Remove("escape"))
+
+	content, err = inner.ReadFile("/jail/secret.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("outside"), content)
+}
+
+func TestBasePathSub(t *testing.T) {
+	fsys, _, _ := newBasePath(t, platform.Windows, "c:\\jail\\root")
+
+	sub, err := fsys.Sub("dir")
+	require.NoError(t, err)
+
+	content, err := iofs.ReadFile(sub, "file.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("inside"), content)
+
+	full, ok := sub.(fs.FS)
+	require.True(t, ok)
+	_, err = full.ReadFile("..\\..\\secret.txt")
+	require.ErrorIs(t, err, iofs.ErrInvalid)
+
+	// link targets are still confined to the sub directory
+	require.NoError(t, full.Symlink("..\\..\\secret.txt", "link"))
+	_, err = full.ReadFile("link")
+	require.ErrorIs(t, err, fs.ErrPathEscapes)
+}
+
+func TestBasePathWithOS(t *testing.T) {
+	path := filepath.NewProviderFromOS(os.New())
+	base := fs.New()
+	dir := t.TempDir()
+	root := path.Join(dir, "root")
+
+	require.NoError(t, base.Mkdir(root, 0755))
+	require.NoError(t, base.WriteFile(path.Join(dir, "secret.txt"), []byte("outside"), 0644))
+
+	fsys := fs.NewBasePath(base, path, root)
+	require.NoError(t, fsys.WriteFile("file.txt", []byte("inside"), 0644))
+
+	content, err := base.ReadFile(path.Join(root, "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("inside"), content)
+
+	_, err = fsys.ReadFile(path.Join("..", "secret.txt"))
+	require.ErrorIs(t, err, fs.ErrPathEscapes)
+
+	matches, err := base.Glob(path.Join(root, "*.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []string{path.Join(root, "file.txt")}, matches)
+	matches, err = fsys.Glob("*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"file.txt"}, matches)
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	require.NoError(t, fsys.Symlink(path.Join(dir, "secret.txt"), "link"))
+	_, err = fsys.ReadFile("link")
+	require.ErrorIs(t, err, fs.ErrPathEscapes)
+}
+
+func TestBasePathGlob(t *testing.T) {
+	fsys, _, _ := newBasePath(t, platform.Windows, "c:\\jail\\root")
+
+	matches, err := fsys.Glob("dir\\*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir\\file.txt"}, matches)
+}
@@ -5,8 +5,13 @@ import (
 	"io"
 	"os"
 	iofs "io/fs"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/patrickhuber/go-cross/filepath"
 	"github.com/patrickhuber/go-cross/fs"
@@ -182,7 +187,7 @@ func (c *conformance) TestCanWriteFile(t *testing.T, path string, files []file)
 		_, err = ofile.Read(buf)
 		require.NoError(t, err)
 		require.Equal(t, file.content, buf)
-		require.Nil(t, f.Close())
+		require.Nil(t, ofile.Close())
 	}
 }
 
@@ -251,3 +256,756 @@ func (c *conformance) TestCanChangePermission(t *testing.T, filePath string) {
 	require.Equal(t, iofs.FileMode(0711), info.Mode())
 	require.NoError(t, f.Close())
 }
+
+func (c *conformance) TestSymlink(t *testing.T, folder string, target string, link string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	targetPath := c.path.Join(folder, target)
+	linkPath := c.path.Join(folder, link)
+
+	err = c.fs.WriteFile(targetPath, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	err = c.fs.Symlink(target, linkPath)
+	require.NoError(t, err)
+
+	content, err := c.fs.ReadFile(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("content"), content)
+
+	destination, err := c.fs.Readlink(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, target, destination)
+
+	info, err := c.fs.Lstat(linkPath)
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&iofs.ModeSymlink)
+
+	info, err = c.fs.Stat(linkPath)
+	require.NoError(t, err)
+	require.Zero(t, info.Mode()&iofs.ModeSymlink)
+	require.Equal(t, int64(len("content")), info.Size())
+
+	err = c.fs.Symlink(target, linkPath)
+	require.ErrorIs(t, err, iofs.ErrExist)
+
+	_, err = c.fs.Readlink(targetPath)
+	require.Error(t, err)
+}
+
+func (c *conformance) TestSymlinkDirectory(t *testing.T, folder string, target string, link string, files []file) {
+	targetPath := c.path.Join(folder, target)
+	linkPath := c.path.Join(folder, link)
+
+	err := c.fs.MkdirAll(targetPath, 0775)
+	require.NoError(t, err)
+
+	err = c.fs.Symlink(targetPath, linkPath)
+	require.NoError(t, err)
+
+	// write through the link and read through the target
+	for _, file := range files {
+		err = c.fs.WriteFile(c.path.Join(linkPath, file.name), file.content, 0644)
+		require.NoError(t, err)
+
+		content, err := c.fs.ReadFile(c.path.Join(targetPath, file.name))
+		require.NoError(t, err)
+		require.Equal(t, file.content, content)
+	}
+
+	entries, err := c.fs.ReadDir(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, len(files), len(entries))
+
+	info, err := c.fs.Stat(linkPath)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+}
+
+func (c *conformance) TestSymlinkLoop(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	first := c.path.Join(folder, "first")
+	second := c.path.Join(folder, "second")
+
+	require.NoError(t, c.fs.Symlink(second, first))
+	require.NoError(t, c.fs.Symlink(first, second))
+
+	_, err = c.fs.Open(first)
+	require.ErrorIs(t, err, syscall.ELOOP)
+
+	_, err = c.fs.Stat(second)
+	require.ErrorIs(t, err, syscall.ELOOP)
+
+	info, err := c.fs.Lstat(first)
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&iofs.ModeSymlink)
+}
+
+func (c *conformance) TestHardLink(t *testing.T, folder string, original string, link string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	originalPath := c.path.Join(folder, original)
+	linkPath := c.path.Join(folder, link)
+
+	err = c.fs.WriteFile(originalPath, []byte("original"), 0644)
+	require.NoError(t, err)
+
+	err = c.fs.Link(originalPath, linkPath)
+	require.NoError(t, err)
+
+	// writes through the link are visible through the original
+	f, err := c.fs.OpenFile(linkPath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(" link"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(originalPath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("original link"), content)
+
+	err = c.fs.Link(originalPath, linkPath)
+	require.ErrorIs(t, err, iofs.ErrExist)
+
+	// removing the original keeps the data for the link
+	err = c.fs.Remove(originalPath)
+	require.NoError(t, err)
+
+	content, err = c.fs.ReadFile(linkPath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("original link"), content)
+}
+
+func (c *conformance) TestChtimes(t *testing.T, filePath string) {
+	dir := c.path.Dir(filePath)
+	err := c.fs.MkdirAll(dir, 0775)
+	require.NoError(t, err)
+
+	err = c.fs.WriteFile(filePath, []byte("test"), 0644)
+	require.NoError(t, err)
+
+	mtime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	atime := time.Date(2021, time.February, 3, 4, 5, 6, 0, time.UTC)
+	err = c.fs.Chtimes(filePath, atime, mtime)
+	require.NoError(t, err)
+
+	info, err := c.fs.Stat(filePath)
+	require.NoError(t, err)
+	require.True(t, mtime.Equal(info.ModTime()))
+
+	// zero times leave the file times unchanged
+	err = c.fs.Chtimes(filePath, time.Time{}, time.Time{})
+	require.NoError(t, err)
+
+	info, err = c.fs.Stat(filePath)
+	require.NoError(t, err)
+	require.True(t, mtime.Equal(info.ModTime()))
+}
+
+func (c *conformance) TestCreateTemp(t *testing.T, folder string, pattern string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	f, err := c.fs.CreateTemp(folder, pattern)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("temp"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	name := f.Name()
+	require.Equal(t, c.path.Clean(folder), c.path.Dir(name))
+
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	base := c.path.Base(name)
+	require.True(t, strings.HasPrefix(base, prefix), "%s does not start with %s", base, prefix)
+	require.True(t, strings.HasSuffix(base, suffix), "%s does not end with %s", base, suffix)
+
+	content, err := c.fs.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, []byte("temp"), content)
+
+	other, err := c.fs.CreateTemp(folder, pattern)
+	require.NoError(t, err)
+	require.NoError(t, other.Close())
+	require.NotEqual(t, name, other.Name())
+
+	_, err = c.fs.CreateTemp(folder, "bad/pattern")
+	require.Error(t, err)
+}
+
+func (c *conformance) TestMkdirTemp(t *testing.T, folder string, pattern string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	dir, err := c.fs.MkdirTemp(folder, pattern)
+	require.NoError(t, err)
+	require.Equal(t, c.path.Clean(folder), c.path.Dir(dir))
+
+	info, err := c.fs.Stat(dir)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+
+	err = c.fs.WriteFile(c.path.Join(dir, "file.txt"), []byte("temp"), 0644)
+	require.NoError(t, err)
+}
+
+func (c *conformance) TestSub(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(c.path.Join(folder, "sub"), 0775)
+	require.NoError(t, err)
+
+	err = c.fs.WriteFile(c.path.Join(folder, "sub", "file.txt"), []byte("sub"), 0644)
+	require.NoError(t, err)
+
+	s, err := c.fs.Sub(c.path.Join(folder, "sub"))
+	require.NoError(t, err)
+
+	sub, ok := s.(fs.FS)
+	require.True(t, ok, "Sub must return a full fs.FS")
+
+	content, err := sub.ReadFile("file.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("sub"), content)
+
+	err = sub.MkdirAll("gran/parent", 0775)
+	require.NoError(t, err)
+
+	err = sub.WriteFile("gran/parent/child.txt", []byte("child"), 0644)
+	require.NoError(t, err)
+
+	err = sub.Rename("gran/parent/child.txt", "gran/renamed.txt")
+	require.NoError(t, err)
+
+	content, err = c.fs.ReadFile(c.path.Join(folder, "sub", "gran", "renamed.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("child"), content)
+
+	// like io/fs.Sub names must be valid io/fs paths
+	_, err = sub.ReadFile("../outside.txt")
+	require.ErrorIs(t, err, iofs.ErrInvalid)
+
+	require.NoError(t, fstest.TestFS(sub, "file.txt", "gran/renamed.txt"))
+
+	_, err = c.fs.Sub(c.path.Join(folder, "sub", "file.txt"))
+	require.Error(t, err)
+}
+
+func (c *conformance) TestHandlesShareData(t *testing.T, filePath string) {
+	err := c.fs.MkdirAll(c.path.Dir(filePath), 0775)
+	require.NoError(t, err)
+
+	err = c.fs.WriteFile(filePath, []byte("0123456789"), 0644)
+	require.NoError(t, err)
+
+	writer, err := c.fs.OpenFile(filePath, os.O_RDWR, 0)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	reader, err := c.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// writes through one handle are visible through the other
+	_, err = writer.WriteAt([]byte("abc"), 3)
+	require.NoError(t, err)
+
+	buf := make([]byte, 10)
+	_, err = reader.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("012abc6789"), buf)
+
+	// writes past the end grow the file for every handle
+	_, err = writer.WriteAt([]byte("end"), 12)
+	require.NoError(t, err)
+
+	info, err := reader.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(15), info.Size())
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, []byte("012abc6789\x00\x00end"), content)
+
+	// each handle keeps its own offset
+	_, err = writer.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("X"))
+	require.NoError(t, err)
+
+	offset, err := reader.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	require.Equal(t, int64(15), offset)
+}
+
+func (c *conformance) TestConcurrentAccess(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	shared := c.path.Join(folder, "shared.txt")
+	err = c.fs.WriteFile(shared, make([]byte, 64), 0644)
+	require.NoError(t, err)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*4)
+	for i := 0; i < workers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := c.path.Join(folder, "file"+strconv.Itoa(i)+".txt")
+			dir := c.path.Join(folder, "dir"+strconv.Itoa(i), "sub")
+			for j := 0; j < 20; j++ {
+				if err := c.fs.WriteFile(name, []byte(strconv.Itoa(j)), 0644); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := c.fs.ReadFile(name); err != nil {
+					errs <- err
+					return
+				}
+				if err := c.fs.MkdirAll(dir, 0775); err != nil {
+					errs <- err
+					return
+				}
+				if err := c.fs.Rename(name, name+".renamed"); err != nil {
+					errs <- err
+					return
+				}
+				if err := c.fs.Rename(name+".renamed", name); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := c.fs.ReadDir(folder); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := c.fs.Stat(shared); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+
+		// every worker writes its own byte of the shared file through its own handle
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := c.fs.OpenFile(shared, os.O_RDWR, 0)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer f.Close()
+			for j := 0; j < 20; j++ {
+				if _, err := f.WriteAt([]byte{byte('a' + i)}, int64(i)); err != nil {
+					errs <- err
+					return
+				}
+				buf := make([]byte, 64)
+				if _, err := f.ReadAt(buf, 0); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	content, err := c.fs.ReadFile(shared)
+	require.NoError(t, err)
+	require.Len(t, content, 64)
+	for i := 0; i < workers; i++ {
+		require.Equal(t, byte('a'+i), content[i])
+	}
+}
+
+func (c *conformance) TestOpenFileFlags(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	existing := c.path.Join(folder, "existing.txt")
+	err = c.fs.WriteFile(existing, []byte("data"), 0644)
+	require.NoError(t, err)
+
+	requirePathError := func(t *testing.T, err error, op string) {
+		var perr *iofs.PathError
+		require.ErrorAs(t, err, &perr)
+		require.Equal(t, op, perr.Op)
+	}
+
+	// missing files are only created with O_CREATE
+	_, err = c.fs.OpenFile(c.path.Join(folder, "missing.txt"), os.O_RDWR, 0644)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	requirePathError(t, err, "open")
+
+	// exclusive creation fails for existing files
+	_, err = c.fs.OpenFile(existing, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	require.ErrorIs(t, err, iofs.ErrExist)
+	requirePathError(t, err, "open")
+
+	f, err := c.fs.OpenFile(c.path.Join(folder, "exclusive.txt"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// read only handles can't write
+	f, err = c.fs.OpenFile(existing, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("x"))
+	requirePathError(t, err, "write")
+	_, err = f.WriteAt([]byte("x"), 0)
+	require.Error(t, err)
+	require.NoError(t, f.Close())
+
+	// write only handles can't read
+	f, err = c.fs.OpenFile(existing, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Read(make([]byte, 4))
+	requirePathError(t, err, "read")
+	require.NoError(t, f.Close())
+
+	// append mode writes at the end even after seeking
+	f, err = c.fs.OpenFile(existing, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("a"))
+	require.NoError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("b"))
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("c"), 0)
+	require.Error(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := c.fs.ReadFile(existing)
+	require.NoError(t, err)
+	require.Equal(t, []byte("dataab"), content)
+
+	// truncation empties the file
+	f, err = c.fs.OpenFile(existing, os.O_RDWR|os.O_TRUNC, 0)
+	require.NoError(t, err)
+	info, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), info.Size())
+
+	// closed handles fail
+	require.NoError(t, f.Close())
+	_, err = f.Write([]byte("closed"))
+	require.ErrorIs(t, err, iofs.ErrClosed)
+	require.ErrorIs(t, f.Close(), iofs.ErrClosed)
+
+	// directories can't be opened for writing
+	_, err = c.fs.OpenFile(folder, os.O_WRONLY, 0)
+	require.Error(t, err)
+	requirePathError(t, err, "open")
+}
+
+func (c *conformance) TestRenameDirectory(t *testing.T, folder string) {
+	src := c.path.Join(folder, "src")
+	err := c.fs.MkdirAll(c.path.Join(src, "sub"), 0775)
+	require.NoError(t, err)
+	err = c.fs.WriteFile(c.path.Join(src, "sub", "file.txt"), []byte("file"), 0644)
+	require.NoError(t, err)
+
+	// move to a different parent
+	err = c.fs.MkdirAll(c.path.Join(folder, "other"), 0775)
+	require.NoError(t, err)
+	dst := c.path.Join(folder, "other", "dst")
+	err = c.fs.Rename(src, dst)
+	require.NoError(t, err)
+
+	content, err := c.fs.ReadFile(c.path.Join(dst, "sub", "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("file"), content)
+
+	_, err = c.fs.Stat(c.path.Join(src, "sub", "file.txt"))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	_, err = c.fs.Stat(src)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	entries, err := c.fs.ReadDir(c.path.Join(dst, "sub"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// a directory can't be moved below itself
+	err = c.fs.Rename(dst, c.path.Join(dst, "sub", "inner"))
+	require.Error(t, err)
+
+	// a missing parent fails
+	err = c.fs.Rename(dst, c.path.Join(folder, "missing", "dst"))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func (c *conformance) TestRenameOverExisting(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(c.path.Join(folder, "dir"), 0775)
+	require.NoError(t, err)
+	err = c.fs.MkdirAll(c.path.Join(folder, "empty"), 0775)
+	require.NoError(t, err)
+	err = c.fs.WriteFile(c.path.Join(folder, "one.txt"), []byte("one"), 0644)
+	require.NoError(t, err)
+	err = c.fs.WriteFile(c.path.Join(folder, "two.txt"), []byte("two"), 0644)
+	require.NoError(t, err)
+
+	// files replace files
+	err = c.fs.Rename(c.path.Join(folder, "one.txt"), c.path.Join(folder, "two.txt"))
+	require.NoError(t, err)
+	content, err := c.fs.ReadFile(c.path.Join(folder, "two.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("one"), content)
+
+	// files don't replace directories
+	var lerr *os.LinkError
+	err = c.fs.Rename(c.path.Join(folder, "two.txt"), c.path.Join(folder, "dir"))
+	require.ErrorAs(t, err, &lerr)
+
+	// directories don't replace files
+	err = c.fs.Rename(c.path.Join(folder, "dir"), c.path.Join(folder, "two.txt"))
+	require.ErrorAs(t, err, &lerr)
+
+	// directories don't replace directories
+	err = c.fs.Rename(c.path.Join(folder, "dir"), c.path.Join(folder, "empty"))
+	require.ErrorAs(t, err, &lerr)
+
+	// missing sources fail
+	err = c.fs.Rename(c.path.Join(folder, "missing.txt"), c.path.Join(folder, "other.txt"))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func (c *conformance) TestRemove(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(c.path.Join(folder, "full"), 0775)
+	require.NoError(t, err)
+	err = c.fs.MkdirAll(c.path.Join(folder, "empty"), 0775)
+	require.NoError(t, err)
+	err = c.fs.WriteFile(c.path.Join(folder, "full", "file.txt"), []byte("file"), 0644)
+	require.NoError(t, err)
+
+	err = c.fs.Remove(c.path.Join(folder, "full"))
+	require.ErrorIs(t, err, syscall.ENOTEMPTY)
+	var perr *iofs.PathError
+	require.ErrorAs(t, err, &perr)
+
+	ok, err := c.fs.Exists(c.path.Join(folder, "full", "file.txt"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	err = c.fs.Remove(c.path.Join(folder, "empty"))
+	require.NoError(t, err)
+
+	err = c.fs.Remove(c.path.Join(folder, "full", "file.txt"))
+	require.NoError(t, err)
+
+	err = c.fs.Remove(c.path.Join(folder, "full"))
+	require.NoError(t, err)
+
+	err = c.fs.Remove(c.path.Join(folder, "missing"))
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func (c *conformance) TestRemoveAll(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(c.path.Join(folder, "a", "b", "c"), 0775)
+	require.NoError(t, err)
+	err = c.fs.MkdirAll(c.path.Join(folder, "a", "bc"), 0775)
+	require.NoError(t, err)
+	err = c.fs.WriteFile(c.path.Join(folder, "a", "b", "c", "file.txt"), []byte("file"), 0644)
+	require.NoError(t, err)
+	err = c.fs.WriteFile(c.path.Join(folder, "a", "bc", "file.txt"), []byte("file"), 0644)
+	require.NoError(t, err)
+	err = c.fs.WriteFile(c.path.Join(folder, "a", "b.txt"), []byte("file"), 0644)
+	require.NoError(t, err)
+
+	err = c.fs.RemoveAll(c.path.Join(folder, "a", "b"))
+	require.NoError(t, err)
+
+	ok, err := c.fs.Exists(c.path.Join(folder, "a", "b"))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// siblings sharing the prefix are kept
+	for _, name := range []string{c.path.Join("bc", "file.txt"), "b.txt"} {
+		ok, err = c.fs.Exists(c.path.Join(folder, "a", name))
+		require.NoError(t, err)
+		require.True(t, ok, name)
+	}
+
+	// missing paths are not an error
+	err = c.fs.RemoveAll(c.path.Join(folder, "missing"))
+	require.NoError(t, err)
+}
+
+func (c *conformance) TestCreateRequiresParent(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	file := c.path.Join(folder, "file.txt")
+	err = c.fs.WriteFile(file, []byte("file"), 0644)
+	require.NoError(t, err)
+
+	type test struct {
+		name   string
+		create func(name string) error
+	}
+	tests := []test{
+		{"write_file", func(name string) error { return c.fs.WriteFile(name, []byte("data"), 0644) }},
+		{"create", func(name string) error {
+			f, err := c.fs.Create(name)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+		{"open_file", func(name string) error {
+			f, err := c.fs.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0644)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.create(c.path.Join(folder, "missing", "new.txt"))
+			require.ErrorIs(t, err, iofs.ErrNotExist)
+			var perr *iofs.PathError
+			require.ErrorAs(t, err, &perr)
+
+			err = test.create(c.path.Join(file, "new.txt"))
+			require.ErrorIs(t, err, syscall.ENOTDIR)
+		})
+	}
+}
+
+func (c *conformance) TestGlob(t *testing.T, folder string) {
+	for _, dir := range []string{"b", "a", c.path.Join("a", "sub")} {
+		err := c.fs.MkdirAll(c.path.Join(folder, dir), 0775)
+		require.NoError(t, err)
+	}
+	for _, name := range []string{
+		c.path.Join("a", "one.txt"),
+		c.path.Join("a", "two.md"),
+		c.path.Join("a", "sub", "three.txt"),
+		c.path.Join("b", "four.txt"),
+	} {
+		err := c.fs.WriteFile(c.path.Join(folder, name), []byte(name), 0644)
+		require.NoError(t, err)
+	}
+
+	type test struct {
+		pattern  string
+		expected []string
+	}
+	tests := []test{
+		{c.path.Join(folder, "*", "*.txt"), []string{
+			c.path.Join(folder, "a", "one.txt"),
+			c.path.Join(folder, "b", "four.txt"),
+		}},
+		{c.path.Join(folder, "a", "*"), []string{
+			c.path.Join(folder, "a", "one.txt"),
+			c.path.Join(folder, "a", "sub"),
+			c.path.Join(folder, "a", "two.md"),
+		}},
+		{c.path.Join(folder, "a", "sub", "three.txt"), []string{
+			c.path.Join(folder, "a", "sub", "three.txt"),
+		}},
+		{c.path.Join(folder, "?", "t*"), []string{
+			c.path.Join(folder, "a", "two.md"),
+		}},
+		{c.path.Join(folder, "c", "*"), nil},
+	}
+	for _, test := range tests {
+		matches, err := c.fs.Glob(test.pattern)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, matches, test.pattern)
+	}
+
+	_, err := c.fs.Glob(c.path.Join(folder, "[", "*"))
+	require.Error(t, err)
+}
+
+func (c *conformance) TestReadDirSorted(t *testing.T, folder string) {
+	err := c.fs.MkdirAll(folder, 0775)
+	require.NoError(t, err)
+
+	expected := []string{"a.txt", "b", "c.txt", "d", "e.txt", "f.txt"}
+	for _, i := range []int{4, 1, 5, 0, 3, 2} {
+		name := c.path.Join(folder, expected[i])
+		if strings.HasSuffix(name, ".txt") {
+			err = c.fs.WriteFile(name, nil, 0644)
+		} else {
+			err = c.fs.Mkdir(name, 0775)
+		}
+		require.NoError(t, err)
+	}
+
+	entries, err := c.fs.ReadDir(folder)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, expected, names)
+}
+
+func (c *conformance) TestWriteDirectory(t *testing.T, folder string) {
+	dir := c.path.Join(folder, "dir")
+	err := c.fs.MkdirAll(dir, 0775)
+	require.NoError(t, err)
+
+	// creating or writing a file over a directory fails without replacing it
+	_, err = c.fs.Create(dir)
+	var perr *iofs.PathError
+	require.ErrorAs(t, err, &perr)
+
+	err = c.fs.WriteFile(dir, []byte("data"), 0644)
+	require.ErrorAs(t, err, &perr)
+
+	info, err := c.fs.Stat(dir)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+}
+
+func (c *conformance) TestPreservesCase(t *testing.T, folder string) {
+	require.NoError(t, c.fs.MkdirAll(c.path.Join(folder, "Documents"), 0775))
+	require.NoError(t, c.fs.WriteFile(c.path.Join(folder, "Documents", "ReadMe.TXT"), []byte("readme"), 0644))
+
+	// lookups ignore case
+	content, err := c.fs.ReadFile(strings.ToUpper(c.path.Join(folder, "documents", "readme.txt")))
+	require.NoError(t, err)
+	require.Equal(t, []byte("readme"), content)
+
+	// names keep the case they were created with
+	info, err := c.fs.Stat(c.path.Join(folder, "DOCUMENTS", "readme.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "ReadMe.TXT", info.Name())
+	require.Equal(t, []string{"Documents"}, names(t, c.fs, folder))
+	require.Equal(t, []string{"ReadMe.TXT"}, names(t, c.fs, c.path.Join(folder, "documents")))
+
+	matches, err := c.fs.Glob(c.path.Join(folder, "Documents", "*.txt"))
+	require.NoError(t, err)
+	require.Equal(t, []string{c.path.Join(folder, "Documents", "ReadMe.TXT")}, matches)
+
+	// writing with a different case keeps the original name
+	require.NoError(t, c.fs.WriteFile(c.path.Join(folder, "documents", "README.txt"), []byte("changed"), 0644))
+	require.Equal(t, []string{"ReadMe.TXT"}, names(t, c.fs, c.path.Join(folder, "Documents")))
+
+	// renaming to a different case changes the name
+	require.NoError(t, c.fs.Rename(
+		c.path.Join(folder, "Documents", "ReadMe.TXT"),
+		c.path.Join(folder, "Documents", "README.md")))
+	require.NoError(t, c.fs.Rename(
+		c.path.Join(folder, "Documents", "README.md"),
+		c.path.Join(folder, "Documents", "Readme.md")))
+	require.Equal(t, []string{"Readme.md"}, names(t, c.fs, c.path.Join(folder, "Documents")))
+
+	// children of renamed directories keep their case
+	require.NoError(t, c.fs.Rename(c.path.Join(folder, "Documents"), c.path.Join(folder, "Docs")))
+	require.Equal(t, []string{"Readme.md"}, names(t, c.fs, c.path.Join(folder, "docs")))
+}
@@ -0,0 +1,456 @@
+package fs
+
+import (
+	iofs "io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+// Fault describes an error injected into a file system operation
+type Fault struct {
+	// Op is the name of the method that fails, for example "WriteFile" or "Open". Methods of files returned from the
+	// file system are prefixed with "File.", for example "File.Write" or "File.Close". An empty Op matches every method
+	Op string
+	// Pattern is matched against the name passed to the method using the separators and case comparison of the path
+	// provider. Each element of the pattern is matched with path.Match. Methods that take two names match when either
+	// name matches and file methods match the name the file was opened with. An empty Pattern matches every name
+	Pattern string
+	// Call is the number of the matching call that fails, counting from one. A Call of zero fails every matching call
+	Call int
+	// Err is the error returned from the failing call. Errors are wrapped in a *fs.PathError or *os.LinkError
+	Err error
+	// Written is the number of bytes written before Err is returned from WriteFile, File.Write and File.WriteAt.
+	// It is used to simulate short writes, other methods fail without calling the wrapped file system
+	Written int
+}
+
+// FaultFS is a file system that fails operations with injected faults
+type FaultFS interface {
+	FS
+	// Inject adds a fault. Faults are checked in the order they were injected and the first one that fails the call wins
+	Inject(fault Fault)
+	// Clear removes all injected faults
+	Clear()
+}
+
+type faultState struct {
+	Fault
+	calls int
+}
+
+type faultFS struct {
+	fs   FS
+	path filepath.Provider
+
+	// mu guards the faults and their call counts
+	mu     sync.Mutex
+	faults []*faultState
+}
+
+// NewFault wraps the file system so operations fail with the injected faults. Calls that don't match a fault are passed
+// to the wrapped file system unchanged. Patterns are matched with the path provider so a Windows flavored file system
+// can use native paths
+func NewFault(fsys FS, path filepath.Provider, faults ...Fault) FaultFS {
+	f := &faultFS{
+		fs:   fsys,
+		path: path,
+	}
+	for _, fault := range faults {
+		f.Inject(fault)
+	}
+	return f
+}
+
+// Inject implements FaultFS
+func (f *faultFS) Inject(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = append(f.faults, &faultState{Fault: fault})
+}
+
+// Clear implements FaultFS
+func (f *faultFS) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+}
+
+// check returns the fault that fails the call to op with the given names or nil if the call succeeds. Every matching
+// fault counts the call even if an earlier fault fails it
+func (f *faultFS) check(op string, names ...string) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var failed *Fault
+	for _, state := range f.faults {
+		if state.Op != "" && state.Op != op {
+			continue
+		}
+		if !f.matches(state.Pattern, names) {
+			continue
+		}
+		state.calls++
+		if failed == nil && (state.Call == 0 || state.Call == state.calls) {
+			failed = &state.Fault
+		}
+	}
+	return failed
+}
+
+func (f *faultFS) matches(pattern string, names []string) bool {
+	if pattern == "" {
+		return true
+	}
+	for _, name := range names {
+		if ok, _ := match(f.path, pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// errorOp converts the method name of a fault to the operation name used in errors
+func errorOp(op string) string {
+	return strings.ToLower(strings.TrimPrefix(op, "File."))
+}
+
+func (f *faultFS) pathError(op string, name string) error {
+	if fault := f.check(op, name); fault != nil {
+		return &iofs.PathError{Op: errorOp(op), Path: name, Err: fault.Err}
+	}
+	return nil
+}
+
+func (f *faultFS) linkError(op string, oldname, newname string) error {
+	if fault := f.check(op, oldname, newname); fault != nil {
+		return &os.LinkError{Op: errorOp(op), Old: oldname, New: newname, Err: fault.Err}
+	}
+	return nil
+}
+
+func (f *faultFS) wrap(file File, name string) File {
+	return &faultFile{File: file, fs: f, name: name}
+}
+
+// Open implements FS
+func (f *faultFS) Open(name string) (iofs.File, error) {
+	if err := f.pathError("Open", name); err != nil {
+		return nil, err
+	}
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if full, ok := file.(File); ok {
+		return f.wrap(full, name), nil
+	}
+	return file, nil
+}
+
+// OpenFile implements FS
+func (f *faultFS) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	if err := f.pathError("OpenFile", name); err != nil {
+		return nil, err
+	}
+	file, err := f.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrap(file, name), nil
+}
+
+// Create implements FS
+func (f *faultFS) Create(name string) (File, error) {
+	if err := f.pathError("Create", name); err != nil {
+		return nil, err
+	}
+	file, err := f.fs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrap(file, name), nil
+}
+
+// Rename implements FS
+func (f *faultFS) Rename(oldName, newName string) error {
+	if err := f.linkError("Rename", oldName, newName); err != nil {
+		return err
+	}
+	return f.fs.Rename(oldName, newName)
+}
+
+// Remove implements FS
+func (f *faultFS) Remove(name string) error {
+	if err := f.pathError("Remove", name); err != nil {
+		return err
+	}
+	return f.fs.Remove(name)
+}
+
+// RemoveAll implements FS
+func (f *faultFS) RemoveAll(name string) error {
+	if err := f.pathError("RemoveAll", name); err != nil {
+		return err
+	}
+	return f.fs.RemoveAll(name)
+}
+
+// Glob implements FS. The pattern is matched against the pattern passed to Glob
+func (f *faultFS) Glob(pattern string) ([]string, error) {
+	if err := f.pathError("Glob", pattern); err != nil {
+		return nil, err
+	}
+	return f.fs.Glob(pattern)
+}
+
+// ReadDir implements FS
+func (f *faultFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	if err := f.pathError("ReadDir", name); err != nil {
+		return nil, err
+	}
+	return f.fs.ReadDir(name)
+}
+
+// ReadFile implements FS
+func (f *faultFS) ReadFile(name string) ([]byte, error) {
+	if err := f.pathError("ReadFile", name); err != nil {
+		return nil, err
+	}
+	return f.fs.ReadFile(name)
+}
+
+// WriteFile implements FS. A fault with Written set writes that many bytes before failing
+func (f *faultFS) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	fault := f.check("WriteFile", name)
+	if fault == nil {
+		return f.fs.WriteFile(name, data, perm)
+	}
+	if fault.Written > 0 {
+		if err := f.fs.WriteFile(name, data[:min(fault.Written, len(data))], perm); err != nil {
+			return err
+		}
+	}
+	return &iofs.PathError{Op: "writefile", Path: name, Err: fault.Err}
+}
+
+// Exists implements FS
+func (f *faultFS) Exists(name string) (bool, error) {
+	if err := f.pathError("Exists", name); err != nil {
+		return false, err
+	}
+	return f.fs.Exists(name)
+}
+
+// Stat implements FS
+func (f *faultFS) Stat(name string) (iofs.FileInfo, error) {
+	if err := f.pathError("Stat", name); err != nil {
+		return nil, err
+	}
+	return f.fs.Stat(name)
+}
+
+// Sub implements FS. Calls through the sub file system are checked against faults using their full path
+func (f *faultFS) Sub(dir string) (iofs.FS, error) {
+	if err := f.pathError("Sub", dir); err != nil {
+		return nil, err
+	}
+	return subDir(f, f.path, dir)
+}
+
+// Mkdir implements MakeDirFS
+func (f *faultFS) Mkdir(name string, perm iofs.FileMode) error {
+	if err := f.pathError("Mkdir", name); err != nil {
+		return err
+	}
+	return f.fs.Mkdir(name, perm)
+}
+
+// MkdirAll implements MakeDirFS
+func (f *faultFS) MkdirAll(name string, perm iofs.FileMode) error {
+	if err := f.pathError("MkdirAll", name); err != nil {
+		return err
+	}
+	return f.fs.MkdirAll(name, perm)
+}
+
+// Chmod implements ChmodFS
+func (f *faultFS) Chmod(name string, mode iofs.FileMode) error {
+	if err := f.pathError("Chmod", name); err != nil {
+		return err
+	}
+	return f.fs.Chmod(name, mode)
+}
+
+// Symlink implements SymlinkFS
+func (f *faultFS) Symlink(oldname, newname string) error {
+	if err := f.linkError("Symlink", oldname, newname); err != nil {
+		return err
+	}
+	return f.fs.Symlink(oldname, newname)
+}
+
+// Readlink implements SymlinkFS
+func (f *faultFS) Readlink(name string) (string, error) {
+	if err := f.pathError("Readlink", name); err != nil {
+		return "", err
+	}
+	return f.fs.Readlink(name)
+}
+
+// Lstat implements SymlinkFS
+func (f *faultFS) Lstat(name string) (iofs.FileInfo, error) {
+	if err := f.pathError("Lstat", name); err != nil {
+		return nil, err
+	}
+	return f.fs.Lstat(name)
+}
+
+// Link implements LinkFS
+func (f *faultFS) Link(oldname, newname string) error {
+	if err := f.linkError("Link", oldname, newname); err != nil {
+		return err
+	}
+	return f.fs.Link(oldname, newname)
+}
+
+// Chtimes implements ChtimesFS
+func (f *faultFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := f.pathError("Chtimes", name); err != nil {
+		return err
+	}
+	return f.fs.Chtimes(name, atime, mtime)
+}
+
+// Chown implements ChownFS
+func (f *faultFS) Chown(name string, uid, gid int) error {
+	if err := f.pathError("Chown", name); err != nil {
+		return err
+	}
+	return f.fs.Chown(name, uid, gid)
+}
+
+// Lchown implements ChownFS
+func (f *faultFS) Lchown(name string, uid, gid int) error {
+	if err := f.pathError("Lchown", name); err != nil {
+		return err
+	}
+	return f.fs.Lchown(name, uid, gid)
+}
+
+// CreateTemp implements TempFS. The pattern of a fault is matched against dir
+func (f *faultFS) CreateTemp(dir, pattern string) (File, error) {
+	if err := f.pathError("CreateTemp", dir); err != nil {
+		return nil, err
+	}
+	file, err := f.fs.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrap(file, file.Name()), nil
+}
+
+// MkdirTemp implements TempFS. The pattern of a fault is matched against dir
+func (f *faultFS) MkdirTemp(dir, pattern string) (string, error) {
+	if err := f.pathError("MkdirTemp", dir); err != nil {
+		return "", err
+	}
+	return f.fs.MkdirTemp(dir, pattern)
+}
+
+// Watch implements WatchFS
+func (f *faultFS) Watch(name string, recursive bool) (Watcher, error) {
+	if err := f.pathError("Watch", name); err != nil {
+		return nil, err
+	}
+	return f.fs.Watch(name, recursive)
+}
+
+// faultFile wraps a file so calls through the handle can fail with injected faults
+type faultFile struct {
+	File
+	fs   *faultFS
+	name string
+}
+
+func (f *faultFile) Stat() (iofs.FileInfo, error) {
+	if err := f.fs.pathError("File.Stat", f.name); err != nil {
+		return nil, err
+	}
+	return f.File.Stat()
+}
+
+func (f *faultFile) Read(b []byte) (int, error) {
+	if err := f.fs.pathError("File.Read", f.name); err != nil {
+		return 0, err
+	}
+	return f.File.Read(b)
+}
+
+func (f *faultFile) ReadAt(b []byte, offset int64) (int, error) {
+	if err := f.fs.pathError("File.ReadAt", f.name); err != nil {
+		return 0, err
+	}
+	return f.File.ReadAt(b, offset)
+}
+
+// Write writes b to the file. A fault with Written set writes that many bytes before failing
+func (f *faultFile) Write(b []byte) (int, error) {
+	fault := f.fs.check("File.Write", f.name)
+	if fault == nil {
+		return f.File.Write(b)
+	}
+	n := 0
+	if fault.Written > 0 {
+		var err error
+		n, err = f.File.Write(b[:min(fault.Written, len(b))])
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, &iofs.PathError{Op: "write", Path: f.name, Err: fault.Err}
+}
+
+// WriteAt writes b to the file at offset. A fault with Written set writes that many bytes before failing
+func (f *faultFile) WriteAt(b []byte, offset int64) (int, error) {
+	fault := f.fs.check("File.WriteAt", f.name)
+	if fault == nil {
+		return f.File.WriteAt(b, offset)
+	}
+	n := 0
+	if fault.Written > 0 {
+		var err error
+		n, err = f.File.WriteAt(b[:min(fault.Written, len(b))], offset)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, &iofs.PathError{Op: "writeat", Path: f.name, Err: fault.Err}
+}
+
+func (f *faultFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.fs.pathError("File.Seek", f.name); err != nil {
+		return 0, err
+	}
+	return f.File.Seek(offset, whence)
+}
+
+// Close closes the file. The underlying file is closed even when a fault fails the call so handles are not leaked
+func (f *faultFile) Close() error {
+	err := f.fs.pathError("File.Close", f.name)
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadDir reads the entries of a directory unless a "File.ReadDir" fault fails the call
+func (f *faultFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	if err := f.fs.pathError("File.ReadDir", f.name); err != nil {
+		return nil, err
+	}
+	return readDir(f.File, f.Name(), n)
+}
@@ -0,0 +1,143 @@
+package fs_test
+
+import (
+	"io"
+	iofs "io/fs"
+	gos "os"
+	"syscall"
+	"testing"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func newFault(t *testing.T, plat platform.Platform, dir string) (fs.FaultFS, filepath.Provider) {
+	fsys, path := newMemory(newOS(plat))
+	require.NoError(t, fsys.MkdirAll(dir, 0755))
+	return fs.NewFault(fsys, path), path
+}
+
+func TestFaultOperations(t *testing.T) {
+	type test struct {
+		name  string
+		fault fs.Fault
+		call  func(fs.FS) error
+	}
+	tests := []test{
+		{"write_file", fs.Fault{Op: "WriteFile", Err: syscall.ENOSPC}, func(fsys fs.FS) error {
+			return fsys.WriteFile("/app/file.txt", []byte("data"), 0644)
+		}},
+		{"open", fs.Fault{Op: "Open", Err: syscall.EACCES}, func(fsys fs.FS) error {
+			_, err := fsys.Open("/app/existing.txt")
+			return err
+		}},
+		{"mkdir_all", fs.Fault{Op: "MkdirAll", Err: syscall.EROFS}, func(fsys fs.FS) error {
+			return fsys.MkdirAll("/app/sub/dir", 0755)
+		}},
+		{"rename", fs.Fault{Op: "Rename", Pattern: "/app/*.bak", Err: syscall.EXDEV}, func(fsys fs.FS) error {
+			return fsys.Rename("/app/existing.txt", "/app/existing.bak")
+		}},
+		{"file_close", fs.Fault{Op: "File.Close", Err: syscall.EIO}, func(fsys fs.FS) error {
+			f, err := fsys.Create("/app/file.txt")
+			require.NoError(t, err)
+			return f.Close()
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys, _ := newFault(t, platform.Linux, "/app")
+			require.NoError(t, fsys.WriteFile("/app/existing.txt", []byte("existing"), 0644))
+
+			fsys.Inject(test.fault)
+			err := test.call(fsys)
+			require.ErrorIs(t, err, test.fault.Err)
+
+			// clearing the faults lets the call succeed
+			fsys.Clear()
+			require.NoError(t, test.call(fsys))
+		})
+	}
+}
+
+func TestFaultErrorTypes(t *testing.T) {
+	fsys, _ := newFault(t, platform.Linux, "/app")
+	fsys.Inject(fs.Fault{Err: syscall.EIO})
+
+	_, err := fsys.Stat("/app")
+	var perr *iofs.PathError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, "stat", perr.Op)
+	require.Equal(t, "/app", perr.Path)
+
+	err = fsys.Symlink("/app", "/link")
+	var lerr *gos.LinkError
+	require.ErrorAs(t, err, &lerr)
+	require.Equal(t, "symlink", lerr.Op)
+	require.ErrorIs(t, err, syscall.EIO)
+}
+
+func TestFaultNthCall(t *testing.T) {
+	fsys, _ := newFault(t, platform.Linux, "/app")
+	fsys.Inject(fs.Fault{Op: "WriteFile", Pattern: "/app/*.log", Call: 3, Err: syscall.ENOSPC})
+
+	// calls that don't match the pattern are not counted
+	require.NoError(t, fsys.WriteFile("/app/data.txt", nil, 0644))
+	require.NoError(t, fsys.WriteFile("/app/one.log", nil, 0644))
+	require.NoError(t, fsys.WriteFile("/app/two.log", nil, 0644))
+	require.ErrorIs(t, fsys.WriteFile("/app/three.log", nil, 0644), syscall.ENOSPC)
+	require.NoError(t, fsys.WriteFile("/app/four.log", nil, 0644))
+
+	ok, err := fsys.Exists("/app/three.log")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestFaultShortWrite(t *testing.T) {
+	fsys, _ := newFault(t, platform.Linux, "/app")
+	fsys.Inject(fs.Fault{Op: "File.Write", Err: io.ErrShortWrite, Written: 3})
+	fsys.Inject(fs.Fault{Op: "WriteFile", Err: syscall.ENOSPC, Written: 2})
+
+	f, err := fsys.Create("/app/file.txt")
+	require.NoError(t, err)
+	n, err := f.Write([]byte("hello"))
+	require.ErrorIs(t, err, io.ErrShortWrite)
+	require.Equal(t, 3, n)
+	require.NoError(t, f.Close())
+
+	content, err := fsys.ReadFile("/app/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("hel"), content)
+
+	require.ErrorIs(t, fsys.WriteFile("/app/other.txt", []byte("hello"), 0644), syscall.ENOSPC)
+	content, err = fsys.ReadFile("/app/other.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("he"), content)
+}
+
+func TestFaultWindowsPatterns(t *testing.T) {
+	fsys, _ := newFault(t, platform.Windows, `c:\app\data`)
+	fsys.Inject(fs.Fault{Op: "OpenFile", Pattern: `c:\app\*\*.txt`, Err: syscall.EACCES})
+
+	// patterns use native separators and the case comparison of the platform
+	_, err := fsys.OpenFile(`C:\App\Data\File.TXT`, gos.O_CREATE|gos.O_WRONLY, 0644)
+	require.ErrorIs(t, err, syscall.EACCES)
+
+	f, err := fsys.OpenFile(`c:\app\data\file.json`, gos.O_CREATE|gos.O_WRONLY, 0644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestFaultWithOS(t *testing.T) {
+	path := filepath.NewProviderFromOS(os.New())
+	dir := t.TempDir()
+	fsys := fs.NewFault(fs.New(), path, fs.Fault{Op: "WriteFile", Pattern: path.Join(dir, "*"), Err: syscall.ENOSPC})
+
+	err := fsys.WriteFile(path.Join(dir, "file.txt"), []byte("data"), 0644)
+	require.ErrorIs(t, err, syscall.ENOSPC)
+
+	require.NoError(t, fsys.Mkdir(path.Join(dir, "sub"), 0755))
+	require.NoError(t, fsys.WriteFile(path.Join(dir, "sub", "file.txt"), []byte("data"), 0644))
+}
@@ -1,14 +1,20 @@
 package fs
 
 import (
+	"errors"
 	"io"
 	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing/fstest"
 	"time"
 )
 
 type File interface {
 	fs.File
+	Name() string
 	io.ReaderAt
 	io.Writer
 	io.WriterAt
@@ -26,40 +32,154 @@ func (i *infoFile) Mode() fs.FileMode          { return i.file.Mode }
 func (i *infoFile) Type() fs.FileMode          { return i.file.Mode.Type() }
 func (i *infoFile) ModTime() time.Time         { return i.file.ModTime }
 func (i *infoFile) IsDir() bool                { return i.file.Mode&fs.ModeDir != 0 }
-func (i *infoFile) Sys() any                   { return i.file.Sys }
 func (i *infoFile) Info() (fs.FileInfo, error) { return i, nil }
 
+func (i *infoFile) Sys() any {
+	// return a copy so callers can't modify the file's stat
+	if stat, ok := i.file.Sys.(*MemoryStat); ok {
+		clone := *stat
+		return &clone
+	}
+	return i.file.Sys
+}
+
+// snapshot returns file info that does not change when the file is modified. The caller must hold the file system lock
+func snapshot(name string, file *fstest.MapFile) *infoFile {
+	clone := *file
+	if stat, ok := file.Sys.(*MemoryStat); ok {
+		sys := *stat
+		clone.Sys = &sys
+	}
+	return &infoFile{name: name, file: &clone}
+}
+
+// errWriteAtInAppendMode is returned from WriteAt on handles opened with O_APPEND
+var errWriteAtInAppendMode = errors.New("invalid use of WriteAt on file opened with O_APPEND")
+
 type openFile struct {
 	path string
 	infoFile
+	// flag is the flag the file was opened with
+	flag int
+	// mu is the file system lock that guards the file shared by every handle
+	mu *sync.RWMutex
+	// seek guards the offset so a handle can be used from multiple goroutines
+	seek   sync.Mutex
 	offset int64
+	closed atomic.Bool
+	// written is called with the file system lock held after data is written through the handle
+	written func()
+	// entries lists the directory with the file system lock held
+	entries func() []fs.DirEntry
+	// unread are the directory entries not yet returned from ReadDir, they are listed on the first call
+	unread []fs.DirEntry
+	listed bool
+}
+
+// Name returns the name of the file as presented to Open
+func (f *openFile) Name() string {
+	return f.path
 }
 
 func (f *openFile) Stat() (fs.FileInfo, error) {
-	return f, nil
+	if f.closed.Load() {
+		return nil, &fs.PathError{Op: "stat", Path: f.path, Err: fs.ErrClosed}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return snapshot(f.name, f.file), nil
+}
+
+// ReadDir reads up to n entries of the directory sorted by name. If n <= 0 all remaining entries are returned
+func (f *openFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.seek.Lock()
+	defer f.seek.Unlock()
+
+	if f.closed.Load() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.path, Err: fs.ErrClosed}
+	}
+	if f.file.Mode&fs.ModeDir == 0 || f.entries == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.path, Err: syscall.ENOTDIR}
+	}
+	if !f.listed {
+		f.mu.RLock()
+		f.unread = f.entries()
+		f.mu.RUnlock()
+		f.listed = true
+	}
+
+	if n <= 0 {
+		entries := append([]fs.DirEntry{}, f.unread...)
+		f.unread = nil
+		return entries, nil
+	}
+	if len(f.unread) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(f.unread))
+	entries := f.unread[:n:n]
+	f.unread = f.unread[n:]
+	return entries, nil
 }
 
 func (f *openFile) Close() error {
+	if f.closed.Swap(true) {
+		return &fs.PathError{Op: "close", Path: f.path, Err: fs.ErrClosed}
+	}
 	return nil
 }
 
-func (f *openFile) Read(b []byte) (int, error) {
-	op := "read"
+// checkRead returns an error if the handle is closed or was opened write only
+func (f *openFile) checkRead(op string) error {
+	if f.closed.Load() {
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrClosed}
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return &fs.PathError{Op: op, Path: f.path, Err: syscall.EBADF}
+	}
 	if f.file.Mode&fs.ModeDir != 0 {
-		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+		return &fs.PathError{Op: op, Path: f.path, Err: syscall.EISDIR}
+	}
+	return nil
+}
+
+// checkWrite returns an error if the handle is closed or was opened read only
+func (f *openFile) checkWrite(op string) error {
+	if f.closed.Load() {
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return &fs.PathError{Op: op, Path: f.path, Err: syscall.EBADF}
+	}
+	return nil
+}
+
+func (f *openFile) Read(b []byte) (int, error) {
+	f.seek.Lock()
+	defer f.seek.Unlock()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if err := f.checkRead("read"); err != nil {
+		return 0, err
 	}
 	if f.offset >= int64(len(f.file.Data)) {
 		return 0, io.EOF
 	}
-	if f.offset < 0 {
-		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
-	}
 	n := copy(b, f.file.Data[f.offset:])
 	f.offset += int64(n)
 	return n, nil
 }
 
 func (f *openFile) Seek(offset int64, whence int) (int64, error) {
+	f.seek.Lock()
+	defer f.seek.Unlock()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.closed.Load() {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrClosed}
+	}
 	switch whence {
 	case io.SeekStart:
 		// offset += 0
@@ -67,8 +187,11 @@ func (f *openFile) Seek(offset int64, whence int) (int64, error) {
 		offset += f.offset
 	case io.SeekEnd:
 		offset += int64(len(f.file.Data))
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
 	}
-	if offset < 0 || offset > int64(len(f.file.Data)) {
+	// seeking past the end is allowed, a later write fills the gap with zeros
+	if offset < 0 {
 		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
 	}
 	f.offset = offset
@@ -76,8 +199,17 @@ func (f *openFile) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (f *openFile) ReadAt(b []byte, offset int64) (int, error) {
-	if offset < 0 || offset > int64(len(f.file.Data)) {
-		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrInvalid}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if err := f.checkRead("read"); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.path, Err: errors.New("negative offset")}
+	}
+	if offset >= int64(len(f.file.Data)) {
+		return 0, io.EOF
 	}
 	n := copy(b, f.file.Data[offset:])
 	if n < len(b) {
@@ -87,42 +219,72 @@ func (f *openFile) ReadAt(b []byte, offset int64) (int, error) {
 }
 
 func (f *openFile) Write(b []byte) (int, error) {
-	op := "write"
-	written, err := f.WriteAt(b, f.offset)
-	if err != nil {
-		return 0, changeOp(err, op)
+	f.seek.Lock()
+	defer f.seek.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWrite("write"); err != nil {
+		return 0, err
 	}
-	f.offset += int64(written)
-	return written, nil
-}
 
-func changeOp(err error, op string) error {
-	perr, ok := err.(*fs.PathError)
-	if !ok {
-		return err
+	// append mode writes every buffer to the current end of the file
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.file.Data))
 	}
-	perr.Op = op
-	return perr
+	n := f.write(b, f.offset)
+	f.offset += int64(n)
+	return n, nil
 }
 
 func (f *openFile) WriteAt(b []byte, offset int64) (int, error) {
-	op := "writeAt"
-	if f.file.Mode&fs.ModeDir != 0 {
-		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	op := "writeat"
+	if err := f.checkWrite(op); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		return 0, &fs.PathError{Op: op, Path: f.path, Err: errWriteAtInAppendMode}
 	}
 	if offset < 0 {
-		return 0, &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+		return 0, &fs.PathError{Op: op, Path: f.path, Err: errors.New("negative offset")}
 	}
+	return f.write(b, offset), nil
+}
 
+// write copies b into the file at offset. The caller must hold the file system lock
+func (f *openFile) write(b []byte, offset int64) int {
+	// writes past the end grow the file and fill any gap with zeros
+	end := int(offset) + len(b)
+	if end > len(f.file.Data) {
+		data := make([]byte, end)
+		copy(data, f.file.Data)
+		f.file.Data = data
+	}
 	copy(f.file.Data[offset:], b)
-	f.file.Data = append(f.file.Data, b[min(len(b), len(f.file.Data)-int(offset)):]...)
+	if f.written != nil {
+		f.written()
+	}
+	return len(b)
+}
 
-	return len(b), nil
+func changeOp(err error, op string) error {
+	perr, ok := err.(*fs.PathError)
+	if !ok {
+		return err
+	}
+	perr.Op = op
+	return perr
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// readDir reads up to n entries of the directory opened as f. Wrapped files that don't support reading directories
+// fail with ErrInvalid using name as the path
+func readDir(f File, name string, n int) ([]fs.DirEntry, error) {
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
 	}
-	return b
+	return dir.ReadDir(n)
 }
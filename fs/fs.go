@@ -2,6 +2,7 @@ package fs
 
 import (
 	iofs "io/fs"
+	"time"
 )
 
 type OpenFileFS interface {
@@ -40,6 +41,53 @@ type ChmodFS interface {
 	Chmod(name string, mode iofs.FileMode) error
 }
 
+// SymlinkFS is a file system that supports symbolic links
+type SymlinkFS interface {
+	// Symlink creates newname as a symbolic link to oldname
+	Symlink(oldname, newname string) error
+	// Readlink returns the destination of the named symbolic link
+	Readlink(name string) (string, error)
+	// Lstat returns a FileInfo describing the named file. If the file is a symbolic link, the returned FileInfo describes the link
+	Lstat(name string) (iofs.FileInfo, error)
+}
+
+// LinkFS is a file system that supports hard links
+type LinkFS interface {
+	// Link creates newname as a hard link to the oldname file
+	Link(oldname, newname string) error
+}
+
+// ChtimesFS is a file system that can change file access and modification times
+type ChtimesFS interface {
+	// Chtimes changes the access and modification times of the named file. A zero time leaves the corresponding file time unchanged
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// ChownFS is a file system that can change file ownership
+type ChownFS interface {
+	// Chown changes the numeric uid and gid of the named file. If the file is a symbolic link, it changes the uid and gid of the link's target. A uid or gid of -1 means to not change that value
+	Chown(name string, uid, gid int) error
+	// Lchown changes the numeric uid and gid of the named file. If the file is a symbolic link, it changes the uid and gid of the link itself
+	Lchown(name string, uid, gid int) error
+}
+
+// TempFS is a file system that can create temporary files and directories
+type TempFS interface {
+	// CreateTemp creates a new temporary file in the directory dir, opens the file for reading and writing and returns the file.
+	// The filename is generated by taking pattern and adding a random string to the end. If pattern includes a "*", the random string replaces the last "*".
+	// If dir is the empty string, CreateTemp uses the default directory for temporary files
+	CreateTemp(dir, pattern string) (File, error)
+	// MkdirTemp creates a new temporary directory in the directory dir and returns the pathname of the new directory.
+	// The directory name follows the same rules as CreateTemp
+	MkdirTemp(dir, pattern string) (string, error)
+}
+
+// WatchFS is a file system that can notify watchers of changes
+type WatchFS interface {
+	// Watch watches the named file or directory for changes. Directories report changes to their children and, when recursive is true, all descendants
+	Watch(name string, recursive bool) (Watcher, error)
+}
+
 type FS interface {
 	iofs.FS
 	OpenFileFS
@@ -56,4 +104,10 @@ type FS interface {
 	iofs.ReadDirFS
 	MakeDirFS
 	ChmodFS
+	SymlinkFS
+	LinkFS
+	ChtimesFS
+	ChownFS
+	TempFS
+	WatchFS
 }
@@ -0,0 +1,136 @@
+package fs
+
+import (
+	iofs "io/fs"
+	"path"
+	"strings"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+// glob expands the pattern one element at a time using the separators and case comparison of the provider.
+// Elements are matched with path.Match. exists reports whether a name exists without following a final symbolic
+// link and names returns the sorted entry names of a directory or nil if it can't be read
+func glob(fp filepath.Provider, pattern string, exists func(name string) bool, names func(dir string) []string) ([]string, error) {
+	parsed, err := fp.Parse(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range parsed.Segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, err
+		}
+	}
+	ignoreCase := fp.Comparison() == filepath.IgnoreCase
+
+	matches := []filepath.FilePath{parsed.Root()}
+	for _, segment := range parsed.Clean().Segments {
+		var next []filepath.FilePath
+		for _, dir := range matches {
+
+			// elements without meta characters only need to exist
+			if !strings.ContainsAny(segment, `*?[\`) {
+				candidate := child(dir, segment)
+				if exists(fp.String(candidate)) {
+					next = append(next, candidate)
+				}
+				continue
+			}
+
+			expr := segment
+			if ignoreCase {
+				expr = strings.ToLower(expr)
+			}
+			for _, name := range names(fp.String(dir)) {
+				target := name
+				if ignoreCase {
+					target = strings.ToLower(target)
+				}
+				if ok, _ := path.Match(expr, target); ok {
+					next = append(next, child(dir, name))
+				}
+			}
+		}
+		matches = next
+	}
+
+	var result []string
+	for _, match := range matches {
+		result = append(result, fp.String(match))
+	}
+	return result, nil
+}
+
+// globFS implements Glob for file systems that list directories with ReadDir
+func globFS(fsys FS, fp filepath.Provider, pattern string) ([]string, error) {
+	exists := func(name string) bool {
+		_, err := fsys.Lstat(name)
+		return err == nil
+	}
+	names := func(dir string) []string {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	return glob(fp, pattern, exists, names)
+}
+
+// globPaths implements Glob for file systems whose names are io/fs paths. The pattern is expanded by io/fs.Glob
+// through globDirs so it is not passed back to the Glob method of the file system
+func globPaths(fsys FS, pattern string) ([]string, error) {
+	return iofs.Glob(globDirs{fsys: fsys}, pattern)
+}
+
+// globDirs exposes only the methods io/fs.Glob needs to walk a file system
+type globDirs struct {
+	fsys FS
+}
+
+func (g globDirs) Open(name string) (iofs.File, error) {
+	return g.fsys.Open(name)
+}
+
+func (g globDirs) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return g.fsys.ReadDir(name)
+}
+
+// match reports whether name matches pattern using the separators and case comparison of the provider. The pattern
+// and name must have the same root and number of elements and each element is matched with path.Match
+func match(fp filepath.Provider, pattern string, name string) (bool, error) {
+	parsedPattern, err := fp.Parse(pattern)
+	if err != nil {
+		return false, err
+	}
+	parsedName, err := fp.Parse(name)
+	if err != nil {
+		return false, nil
+	}
+	parsedPattern = parsedPattern.Clean()
+	parsedName = parsedName.Clean()
+
+	cmp := fp.Comparison()
+	if parsedPattern.Absolute != parsedName.Absolute ||
+		!parsedPattern.Volume.Equal(parsedName.Volume, cmp) ||
+		len(parsedPattern.Segments) != len(parsedName.Segments) {
+		return false, nil
+	}
+	ignoreCase := cmp == filepath.IgnoreCase
+	for i, expr := range parsedPattern.Segments {
+		target := parsedName.Segments[i]
+		if ignoreCase {
+			expr = strings.ToLower(expr)
+			target = strings.ToLower(target)
+		}
+		ok, err := path.Match(expr, target)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,209 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing/fstest"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+// Load copies the files and directories of src into dst.Dir. Paths in src use forward slashes and are translated with
+// the destination path provider, so a testdata tree or embed.FS can be loaded into a file system of any platform.
+// Directories are created with mode 0755 and files with mode 0644, or 0755 if the source file is executable, so
+// read only sources like embed.FS produce writable fixtures. Non zero modification times are preserved.
+//
+// Symbolic links with relative targets are recreated with the target translated to the destination path provider
+// when src can read links with a ReadLink method, like os.DirFS and fstest.MapFS since go 1.25. Other links are
+// loaded as the file or directory tree they point to, skipping links to a directory that contains them and links
+// to targets that don't exist.
+func Load(src iofs.FS, dst Tree) error {
+	if err := dst.FS.MkdirAll(dst.Dir, 0755); err != nil {
+		return err
+	}
+	return load(src, dst, ".", 0)
+}
+
+// load copies the children of root in src. depth is the number of links to directories followed to reach root
+func load(src iofs.FS, dst Tree, root string, depth int) error {
+	return iofs.WalkDir(src, root, func(name string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root {
+			return nil
+		}
+		target := dst.Path.Join(append([]string{dst.Dir}, strings.Split(name, "/")...)...)
+
+		link := d.Type()&iofs.ModeSymlink != 0
+		if link {
+			if oldname, ok := linkTarget(src, name, dst.Path); ok {
+				return dst.FS.Symlink(oldname, target)
+			}
+		}
+
+		info, err := iofs.Stat(src, name)
+		if link && errors.Is(err, iofs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if link {
+				return loadLinkedDir(src, dst, name, info, target, depth)
+			}
+			if err := dst.FS.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			return chtimes(dst.FS, target, info)
+		}
+
+		data, err := iofs.ReadFile(src, name)
+		if err != nil {
+			return err
+		}
+		var perm iofs.FileMode = 0644
+		if info.Mode().Perm()&0111 != 0 {
+			perm = 0755
+		}
+		if err := dst.FS.WriteFile(target, data, perm); err != nil {
+			return err
+		}
+		return chtimes(dst.FS, target, info)
+	})
+}
+
+// readLinkFS is implemented by sources that can read symbolic links, like io/fs.ReadLinkFS since go 1.25
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+// linkTarget returns the relative target of the symbolic link at name translated to the destination path provider.
+// It returns false if src can't read links or the target is absolute
+func linkTarget(src iofs.FS, name string, fp filepath.Provider) (string, bool) {
+	links, ok := src.(readLinkFS)
+	if !ok {
+		return "", false
+	}
+	target, err := links.ReadLink(name)
+	if err != nil || target == "" || path.IsAbs(target) {
+		return "", false
+	}
+	return fp.Join(strings.Split(target, "/")...), true
+}
+
+// loadLinkedDir loads the tree of the directory the link at name points to because WalkDir doesn't follow links.
+// Links to a directory above the link are skipped so loops are not loaded forever
+func loadLinkedDir(src iofs.FS, dst Tree, name string, info iofs.FileInfo, target string, depth int) error {
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		parent, err := iofs.Stat(src, dir)
+		if err != nil {
+			return err
+		}
+		if os.SameFile(info, parent) {
+			return nil
+		}
+		if dir == "." {
+			break
+		}
+	}
+	// file systems without comparable file info can't detect loops
+	if depth >= maxSymlinks {
+		return &iofs.PathError{Op: "load", Path: name, Err: syscall.ELOOP}
+	}
+	if err := dst.FS.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	if err := chtimes(dst.FS, target, info); err != nil {
+		return err
+	}
+	return load(src, dst, name, depth+1)
+}
+
+// chtimes copies the modification time of info to name unless it is zero
+func chtimes(fsys FS, name string, info iofs.FileInfo) error {
+	if info.ModTime().IsZero() {
+		return nil
+	}
+	return fsys.Chtimes(name, info.ModTime(), info.ModTime())
+}
+
+// LoadTxtar copies the files of a txtar archive into dst.Dir. An archive starts with an optional comment followed by
+// files that each begin with a marker line of the form "-- name --". Names use forward slashes and parent directories
+// are created as needed. Like the txtar format, a newline is added to file content that does not end with one.
+func LoadTxtar(archive []byte, dst Tree) error {
+	src, err := parseTxtar(archive)
+	if err != nil {
+		return err
+	}
+	return Load(src, dst)
+}
+
+// parseTxtar returns the files of a txtar archive
+func parseTxtar(archive []byte) (fstest.MapFS, error) {
+	files := fstest.MapFS{}
+	var name string
+	var data []byte
+	inFile := false
+
+	add := func() error {
+		if !inFile {
+			return nil
+		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		clean := path.Clean(name)
+		if !iofs.ValidPath(clean) || clean == "." {
+			return fmt.Errorf("txtar: invalid file name %q", name)
+		}
+		if _, ok := files[clean]; ok {
+			return fmt.Errorf("txtar: duplicate file name %q", name)
+		}
+		files[clean] = &fstest.MapFile{Data: data}
+		return nil
+	}
+
+	for len(archive) > 0 {
+		line := archive
+		if i := bytes.IndexByte(archive, '\n'); i >= 0 {
+			line = archive[:i+1]
+		}
+		archive = archive[len(line):]
+
+		marker, ok := txtarMarker(line)
+		if !ok {
+			if inFile {
+				data = append(data, line...)
+			}
+			continue
+		}
+		if err := add(); err != nil {
+			return nil, err
+		}
+		name = marker
+		data = nil
+		inFile = true
+	}
+	if err := add(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// txtarMarker returns the file name of a "-- name --" marker line
+func txtarMarker(line []byte) (string, bool) {
+	text := strings.TrimRight(string(line), "\r\n")
+	if !strings.HasPrefix(text, "-- ") || !strings.HasSuffix(text, " --") || len(text) < len("-- x --") {
+		return "", false
+	}
+	name := strings.TrimSpace(text[len("-- ") : len(text)-len(" --")])
+	return name, name != ""
+}
@@ -0,0 +1,198 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	gos "os"
+	gofilepath "path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	modTime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	src := fstest.MapFS{
+		"config/app.yaml":  {Data: []byte("app"), Mode: 0444, ModTime: modTime},
+		"bin/run.sh":       {Data: []byte("run"), Mode: 0555},
+		"empty":            {Mode: iofs.ModeDir | 0555},
+		"config/nested/.x": {Data: []byte("x")},
+	}
+	type test struct {
+		name     string
+		platform platform.Platform
+		root     string
+	}
+	tests := []test{
+		{"unix", platform.Linux, "/working"},
+		{"windows", platform.Windows, `c:\working`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys, path := newMemory(newOS(test.platform))
+			require.NoError(t, fs.Load(src, fs.Tree{FS: fsys, Path: path, Dir: test.root}))
+
+			content, err := fsys.ReadFile(path.Join(test.root, "config", "app.yaml"))
+			require.NoError(t, err)
+			require.Equal(t, []byte("app"), content)
+
+			// read only sources produce writable files and keep the executable bit
+			info, err := fsys.Stat(path.Join(test.root, "config", "app.yaml"))
+			require.NoError(t, err)
+			require.Equal(t, iofs.FileMode(0644), info.Mode().Perm())
+			require.True(t, info.ModTime().Equal(modTime))
+			info, err = fsys.Stat(path.Join(test.root, "bin", "run.sh"))
+			require.NoError(t, err)
+			require.Equal(t, iofs.FileMode(0755), info.Mode().Perm())
+
+			info, err = fsys.Stat(path.Join(test.root, "empty"))
+			require.NoError(t, err)
+			require.True(t, info.IsDir())
+
+			ok, err := fsys.Exists(path.Join(test.root, "config", "nested", ".x"))
+			require.NoError(t, err)
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestLoadDirFS(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, gos.MkdirAll(gofilepath.Join(dir, "testdata", "sub"), 0755))
+	require.NoError(t, gos.WriteFile(gofilepath.Join(dir, "testdata", "sub", "file.txt"), []byte("file"), 0644))
+
+	fsys, path := newMemory(newOS(platform.Windows))
+	root := `c:\working`
+	require.NoError(t, fs.Load(gos.DirFS(dir), fs.Tree{FS: fsys, Path: path, Dir: root}))
+
+	content, err := fsys.ReadFile(`c:\working\testdata\sub\file.txt`)
+	require.NoError(t, err)
+	require.Equal(t, []byte("file"), content)
+}
+
+// readLinkFS adds ReadLink to os.DirFS for go versions before 1.25
+type readLinkFS struct {
+	iofs.FS
+	dir string
+}
+
+func (r readLinkFS) ReadLink(name string) (string, error) {
+	target, err := gos.Readlink(gofilepath.Join(r.dir, gofilepath.FromSlash(name)))
+	return gofilepath.ToSlash(target), err
+}
+
+func newLinkedDir(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires elevated privileges on windows")
+	}
+	dir := t.TempDir()
+	require.NoError(t, gos.MkdirAll(gofilepath.Join(dir, "sub", "nested"), 0755))
+	require.NoError(t, gos.WriteFile(gofilepath.Join(dir, "sub", "nested", "file.txt"), []byte("file"), 0644))
+	require.NoError(t, gos.Symlink("sub/nested/file.txt", gofilepath.Join(dir, "link.txt")))
+	require.NoError(t, gos.Symlink("sub", gofilepath.Join(dir, "linked")))
+	require.NoError(t, gos.Symlink("..", gofilepath.Join(dir, "sub", "nested", "loop")))
+	return dir
+}
+
+func TestLoadSymlink(t *testing.T) {
+	dir := newLinkedDir(t)
+	require.NoError(t, gos.Symlink(gofilepath.Join(dir, "sub"), gofilepath.Join(dir, "absolute")))
+
+	fsys, path := newMemory(newOS(platform.Windows))
+	require.NoError(t, fs.Load(readLinkFS{FS: gos.DirFS(dir), dir: dir}, fs.Tree{FS: fsys, Path: path, Dir: `c:\working`}))
+
+	for name, expected := range map[string]string{
+		`c:\working\link.txt`:        `sub\nested\file.txt`,
+		`c:\working\linked`:          "sub",
+		`c:\working\sub\nested\loop`: "..",
+	} {
+		target, err := fsys.Readlink(name)
+		require.NoError(t, err, name)
+		require.Equal(t, expected, target, name)
+	}
+
+	content, err := fsys.ReadFile(`c:\working\linked\nested\file.txt`)
+	require.NoError(t, err)
+	require.Equal(t, []byte("file"), content)
+
+	// links to absolute targets are loaded as the tree they point to
+	info, err := fsys.Lstat(`c:\working\absolute`)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+
+	content, err = fsys.ReadFile(`c:\working\absolute\nested\file.txt`)
+	require.NoError(t, err)
+	require.Equal(t, []byte("file"), content)
+}
+
+func TestLoadSymlinkWithoutReadLink(t *testing.T) {
+	dir := newLinkedDir(t)
+	require.NoError(t, gos.Symlink("missing", gofilepath.Join(dir, "dangling")))
+
+	// the struct hides ReadLink from os.DirFS since go 1.25
+	fsys, path := newMemory(newOS(platform.Linux))
+	require.NoError(t, fs.Load(struct{ iofs.FS }{gos.DirFS(dir)}, fs.Tree{FS: fsys, Path: path, Dir: "/working"}))
+
+	for _, name := range []string{"/working/link.txt", "/working/linked/nested/file.txt"} {
+		info, err := fsys.Lstat(name)
+		require.NoError(t, err, name)
+		require.True(t, info.Mode().IsRegular(), name)
+
+		content, err := fsys.ReadFile(name)
+		require.NoError(t, err, name)
+		require.Equal(t, []byte("file"), content, name)
+	}
+
+	// links to a directory containing the link and links to missing targets are skipped
+	for _, name := range []string{"/working/sub/nested/loop", "/working/linked/nested/loop", "/working/dangling"} {
+		ok, err := fsys.Exists(name)
+		require.NoError(t, err, name)
+		require.False(t, ok, name)
+	}
+}
+
+func TestLoadTxtar(t *testing.T) {
+	archive := []byte(`comment describing the fixture
+-- home/fake/.config/app.yaml --
+name: app
+-- home/fake/.profile --
+export PATH=/bin
+-- empty.txt --
+-- no_newline.txt --
+last`)
+
+	fsys, path := newMemory(newOS(platform.Windows))
+	require.NoError(t, fs.LoadTxtar(archive, fs.Tree{FS: fsys, Path: path, Dir: `c:\`}))
+
+	content, err := fsys.ReadFile(`c:\home\fake\.config\app.yaml`)
+	require.NoError(t, err)
+	require.Equal(t, []byte("name: app\n"), content)
+
+	content, err = fsys.ReadFile(`c:\home\fake\.profile`)
+	require.NoError(t, err)
+	require.Equal(t, []byte("export PATH=/bin\n"), content)
+
+	content, err = fsys.ReadFile(`c:\empty.txt`)
+	require.NoError(t, err)
+	require.Empty(t, content)
+
+	content, err = fsys.ReadFile(`c:\no_newline.txt`)
+	require.NoError(t, err)
+	require.Equal(t, []byte("last\n"), content)
+}
+
+func TestLoadTxtarInvalidNames(t *testing.T) {
+	for _, archive := range []string{
+		"-- ../escape.txt --\ndata\n",
+		"-- /absolute.txt --\ndata\n",
+		"-- file.txt --\none\n-- file.txt --\ntwo\n",
+	} {
+		fsys, path := newMemory(newOS(platform.Linux))
+		err := fs.LoadTxtar([]byte(archive), fs.Tree{FS: fsys, Path: path, Dir: "/working"})
+		require.Error(t, err, archive)
+	}
+}
@@ -1,66 +1,161 @@
 package fs
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
+	"math/rand"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	fstest "testing/fstest"
+	"time"
 
 	"github.com/patrickhuber/go-cross/filepath"
 )
 
+// maxSymlinks is the number of symbolic links followed before a path is considered a loop
+const maxSymlinks = 255
+
+// MemoryStat is returned from FileInfo.Sys for files in the memory file system
+type MemoryStat struct {
+	// Nlink is the number of hard links to the file
+	Nlink uint64
+	// Atime is the last access time of the file
+	Atime time.Time
+	// Uid is the user id of the file owner
+	Uid int
+	// Gid is the group id of the file owner
+	Gid int
+}
+
 type memory struct {
-	fs   fstest.MapFS
-	path filepath.Provider
+	// mu guards the file system map so operations like Rename are atomic with respect to readers
+	mu       sync.RWMutex
+	fs       fstest.MapFS
+	path     filepath.Provider
+	clock    func() time.Time
+	uid      int
+	gid      int
+	tempDir  string
+	random   *rand.Rand
+	watchers []*memoryWatcher
+	enforce  bool
+	umask    fs.FileMode
+	lenient  bool
+	// names holds the final element of each key as it was written so case is preserved
+	names map[string]string
 }
 
-func NewMemory(path filepath.Provider) FS {
-	m := &memory{
-		fs:   fstest.MapFS{},
-		path: path,
+// errPatternHasSeparator is returned when a temporary file pattern contains a path separator
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
+type MemoryOption func(*memory)
+
+// WithClock sets the clock used to stamp modification and access times
+func WithClock(clock func() time.Time) MemoryOption {
+	return func(m *memory) {
+		m.clock = clock
 	}
-	return m
 }
 
-func (m *memory) Create(name string) (File, error) {
-	original := name
-	name, err := m.path.Normalize(name)
-	if err != nil {
-		return nil, err
+// WithUser sets the user and group that own files created in the file system
+func WithUser(uid, gid int) MemoryOption {
+	return func(m *memory) {
+		m.uid = uid
+		m.gid = gid
 	}
+}
 
-	file, ok := m.fs[name]
-	if !ok {
-		file = &fstest.MapFile{}
-		m.fs[name] = file
+// WithPermissions enables checks of the read, write and execute bits of files and directories against the user and
+// group set with WithUser. Operations the user is not allowed to perform fail with fs.ErrPermission. The root user (uid 0)
+// bypasses the checks like it does on the operating system
+func WithPermissions() MemoryOption {
+	return func(m *memory) {
+		m.enforce = true
 	}
-	file.Data = nil
-	file.Mode = 0666
-	return &openFile{
-		path: original,
-		infoFile: infoFile{
-			name: m.path.Base(original),
-			file: file,
-		},
-	}, nil
+}
+
+// WithUmask sets the mask removed from the permissions of files and directories created with Mkdir, MkdirAll,
+// WriteFile, Create, OpenFile, CreateTemp and MkdirTemp
+func WithUmask(umask fs.FileMode) MemoryOption {
+	return func(m *memory) {
+		m.umask = umask & fs.ModePerm
+	}
+}
+
+// WithLenientParents allows files to be created in directories that don't exist. It restores the behavior of earlier
+// versions for tests that never create parent directories
+func WithLenientParents() MemoryOption {
+	return func(m *memory) {
+		m.lenient = true
+	}
+}
+
+// WithTempDir sets the default directory used by CreateTemp and MkdirTemp when dir is empty
+func WithTempDir(dir string) MemoryOption {
+	return func(m *memory) {
+		m.tempDir = dir
+	}
+}
+
+// WithSeed seeds the generator used to name temporary files and directories
+func WithSeed(seed int64) MemoryOption {
+	return func(m *memory) {
+		m.random = rand.New(rand.NewSource(seed))
+	}
+}
+
+func NewMemory(path filepath.Provider, options ...MemoryOption) FS {
+	m := &memory{
+		fs:    fstest.MapFS{},
+		names: map[string]string{},
+		path:  path,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	if m.clock == nil {
+		m.clock = time.Now
+	}
+	if m.random == nil {
+		m.random = rand.New(rand.NewSource(0))
+	}
+	return m
+}
+
+// Create implements FS. It shares the checks of OpenFile
+func (m *memory) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 }
 
 // Open implements FS
 func (m *memory) Open(name string) (fs.File, error) {
-	return m.open(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, err := m.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.can(f.file, accessRead) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f, nil
 }
 
 func (m *memory) open(name string) (*openFile, error) {
 	op := "open"
 	original := name
-	name, err := m.path.Normalize(name)
+	name, f, err := m.resolve(name, true)
 	if err != nil {
-		return nil, err
+		return nil, &fs.PathError{Op: op, Path: original, Err: err}
 	}
 
-	f, ok := m.fs[name]
-	if !ok {
+	if f == nil {
 		return nil, &fs.PathError{
 			Op:   op,
 			Path: original,
@@ -68,284 +163,379 @@ func (m *memory) open(name string) (*openFile, error) {
 		}
 	}
 	return &openFile{
-		path: name,
+		path:    original,
+		flag:    os.O_RDONLY,
+		mu:      &m.mu,
+		written: m.written(name, f),
+		entries: func() []fs.DirEntry { return m.entries(name) },
 		infoFile: infoFile{
-			name: m.path.Base(name),
+			name: m.base(name),
 			file: f,
 		},
 	}, nil
 }
 
-func isReadOnly(mode int) bool {
-	switch {
-	case mode&os.O_APPEND == os.O_APPEND:
-		return false
-	case mode&os.O_CREATE == os.O_CREATE:
-		return false
-	case mode&os.O_TRUNC == os.O_CREATE:
-		return false
-	case mode&os.O_WRONLY == os.O_WRONLY:
-		return false
-	case mode&os.O_RDWR == os.O_RDWR:
-		return false
-	}
-	return true
-}
-
 // OpenFile implements OpenFS
-func (m *memory) OpenFile(name string, mode int, perm fs.FileMode) (File, error) {
-	op := "openFile"
-	original := name
+func (m *memory) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	name, err := m.path.Normalize(name)
+	f, err := m.openFile(name, flag, perm)
 	if err != nil {
 		return nil, err
 	}
+	return f, nil
+}
 
-	f, ok := m.fs[name]
-	if !ok {
-		// for readonly files, if the file doesn't exist return an error
-		if isReadOnly(mode) {
-			return nil, &fs.PathError{
-				Op:   op,
-				Path: original,
-				Err:  fs.ErrNotExist,
-			}
-		}
+// openFile opens the named file with the flags and checks of OpenFile. The caller must hold the file system lock
+func (m *memory) openFile(name string, flag int, perm fs.FileMode) (*openFile, error) {
+	op := "open"
+	original := name
 
-		f = &fstest.MapFile{}
-		m.fs[name] = f
+	name, base, f, err := m.resolveBase(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: original, Err: err}
 	}
 
-	// truncate if O_TRUNC specified
-	if mode&os.O_TRUNC != 0 {
+	switch {
+	case f == nil && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: op, Path: original, Err: fs.ErrNotExist}
+	case f == nil:
+		if !m.canModifyParent(name) {
+			return nil, &fs.PathError{Op: op, Path: original, Err: fs.ErrPermission}
+		}
+		f = m.newFile(perm &^ m.umask)
+		m.add(name, base, f)
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: op, Path: original, Err: fs.ErrExist}
+	case f.Mode.IsDir() && flag&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC) != 0:
+		return nil, &fs.PathError{Op: op, Path: original, Err: syscall.EISDIR}
+	case !m.can(f, access(flag)):
+		return nil, &fs.PathError{Op: op, Path: original, Err: fs.ErrPermission}
+	case flag&os.O_TRUNC != 0:
 		f.Data = nil
-	}
-
-	// seek pos
-	offset := 0
-	if mode&os.O_APPEND != 0 {
-		offset = len(f.Data)
+		m.modified(name, f)
 	}
 
 	return &openFile{
-		path:   name,
-		offset: int64(offset),
+		path:    original,
+		flag:    flag,
+		mu:      &m.mu,
+		written: m.written(name, f),
+		entries: func() []fs.DirEntry { return m.entries(name) },
 		infoFile: infoFile{
-			name: m.path.Base(name),
+			name: m.base(name),
 			file: f,
 		},
 	}, nil
 }
 
-// Rename implements FS
+// Rename implements FS. Directories are moved with all of their descendants. Like os.Rename an existing
+// directory is never replaced and a directory can't be moved below itself
 func (m *memory) Rename(oldPath string, newPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	var err error
+	op := "rename"
+	oldName, newName := oldPath, newPath
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: op, Old: oldName, New: newName, Err: err}
+	}
 
-	oldPath, err = m.path.Normalize(oldPath)
+	oldPath, file, err := m.resolve(oldPath, false)
 	if err != nil {
-		return err
+		return linkErr(err)
 	}
-
-	newPath, err = m.path.Normalize(newPath)
+	newPath, newBase, existing, err := m.resolveBase(newPath, false)
 	if err != nil {
-		return err
+		return linkErr(err)
 	}
 
-	file, ok := m.fs[oldPath]
-	if !ok {
-		return os.ErrNotExist
+	if file == nil {
+		return linkErr(fs.ErrNotExist)
+	}
+	if !m.canModifyParent(oldPath) || !m.canModifyParent(newPath) {
+		return linkErr(fs.ErrPermission)
+	}
+	if oldPath == newPath {
+		// names that only differ in case refer to the same file, keep the new case
+		m.names[newPath] = newBase
+		return nil
+	}
+	if existing == file {
+		return nil
+	}
+	switch {
+	case existing != nil && existing.Mode.IsDir():
+		return linkErr(syscall.EEXIST)
+	case existing != nil && file.Mode.IsDir():
+		return linkErr(syscall.ENOTDIR)
+	case file.Mode.IsDir() && m.isDescendant(newPath, oldPath):
+		return linkErr(syscall.EINVAL)
+	}
+
+	if existing != nil {
+		m.release(newPath)
+	}
+	oldDisplay := m.display(oldPath)
+
+	// move the descendants first so the new directory is complete when watchers are notified
+	if file.Mode.IsDir() {
+		for _, key := range m.descendants(oldPath) {
+			rel := strings.TrimPrefix(key, oldPath)
+			m.fs[newPath+rel] = m.fs[key]
+			m.names[newPath+rel] = m.base(key)
+			delete(m.fs, key)
+			delete(m.names, key)
+		}
 	}
 	delete(m.fs, oldPath)
-	m.fs[newPath] = file
+	delete(m.names, oldPath)
+	m.touchParent(oldPath)
+	m.notifyName(oldPath, oldDisplay, OpRename)
+	m.add(newPath, newBase, file)
 	return nil
 }
 
-// Remove implements FS
+// Remove implements FS. Directories must be empty
 func (m *memory) Remove(path string) error {
-	path, err := m.path.Normalize(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op := "remove"
+	original := path
+	path, file, err := m.lookup(op, path, false)
 	if err != nil {
 		return err
 	}
-	_, ok := m.fs[path]
-	if !ok {
-		return os.ErrNotExist
+	if file.Mode.IsDir() && m.hasChildren(path) {
+		return &fs.PathError{Op: op, Path: original, Err: syscall.ENOTEMPTY}
 	}
-	delete(m.fs, path)
+	if !m.canModifyParent(path) {
+		return &fs.PathError{Op: op, Path: original, Err: fs.ErrPermission}
+	}
+	m.unlink(path)
 	return nil
 }
 
-// RemoveAll implements FS
+// RemoveAll implements FS. Symbolic links are removed without removing their targets and a
+// missing path is not an error
 func (m *memory) RemoveAll(path string) error {
-	paths := []string{}
-	for p := range m.fs {
-		if strings.HasPrefix(p, path) {
-			paths = append(paths, p)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	original := path
+	path, file, err := m.resolve(path, false)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return &fs.PathError{Op: "removeall", Path: original, Err: err}
+	}
+	if file == nil {
+		return nil
+	}
+
+	paths := []string{path}
+	if file.Mode.IsDir() {
+		paths = append(paths, m.descendants(path)...)
+	}
+	for _, p := range paths {
+		if !m.canModifyParent(p) {
+			return &fs.PathError{Op: "unlinkat", Path: p, Err: fs.ErrPermission}
 		}
 	}
+
+	// remove the deepest paths first so children are removed before their parents
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
 	for _, p := range paths {
-		delete(m.fs, p)
+		m.unlink(p)
 	}
 	return nil
 }
 
-// Glob implements FS
+// Glob implements FS. The pattern is split with the provider's separators and matched ignoring case when the provider does
 func (m *memory) Glob(pattern string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	return m.fs.Glob(pattern)
+	exists := func(name string) bool {
+		_, file, err := m.resolve(name, false)
+		return err == nil && file != nil
+	}
+	names := func(dir string) []string {
+		key, d, err := m.resolve(dir, true)
+		if err != nil || d == nil || !d.Mode.IsDir() {
+			return nil
+		}
+		var names []string
+		for _, entry := range m.entries(key) {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	return glob(m.path, pattern, exists, names)
 }
 
 // ReadDir implements FS
 func (m *memory) ReadDir(name string) ([]fs.DirEntry, error) {
-	// check that we can open the file
-	d, err := m.Open(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// check that we can open the file, following any symbolic links
+	// the entries are listed under the resolved directory
+	original := name
+	name, d, err := m.resolve(name, true)
 	if err != nil {
-		return nil, err
+		return nil, &fs.PathError{Op: "open", Path: original, Err: err}
+	}
+	if d == nil {
+		return nil, &fs.PathError{Op: "open", Path: original, Err: fs.ErrNotExist}
+	}
+	if !m.can(d, accessRead) {
+		return nil, &fs.PathError{Op: "open", Path: original, Err: fs.ErrPermission}
 	}
-	defer d.Close()
+	return m.entries(name), nil
+}
 
-	// create the list of entries
+// entries returns the entries of the directory key sorted by name like os.ReadDir
+func (m *memory) entries(key string) []fs.DirEntry {
 	var entries []fs.DirEntry
 	for path, file := range m.fs {
-		originalPath := path
-
-		path, err = m.path.Normalize(path)
-		if err != nil {
-			return nil, err
-		}
-
-		name, err = m.path.Normalize(name)
-		if err != nil {
-			return nil, err
-		}
 
 		// same dir
-		if path == name {
+		if path == key {
 			continue
 		}
 
 		// is the file's the directory the same as the
-		if m.path.Dir(path) == name {
+		if m.path.Dir(path) == key {
 
-			// get the file name
-			fileName := m.path.Base(originalPath)
+			// get the file name as it was written
+			fileName := m.base(path)
 
 			// append
-			entries = append(entries, &infoFile{name: fileName, file: file})
+			entries = append(entries, snapshot(fileName, file))
 		}
 	}
-	return entries, nil
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
 }
 
 // ReadFile implements FS
 func (m *memory) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	f, err := m.Open(name)
+	f, err := m.open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-
-	stat, err := f.Stat()
-	if err != nil {
-		return nil, err
+	if !m.can(f.file, accessRead) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
 	}
 
-	buf := make([]byte, stat.Size())
-	if len(buf) == 0 {
-		return buf, nil
-	}
-
-	_, err = f.Read(buf)
-	if err != nil {
-		return nil, err
-	}
+	// copy the data so the caller can't modify the file
+	buf := make([]byte, len(f.file.Data))
+	copy(buf, f.file.Data)
 	return buf, nil
 }
 
 // WriteFile implements FS
 func (m *memory) WriteFile(name string, data []byte, perm os.FileMode) error {
-	name, err := m.path.Normalize(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the permissions are only applied when the file is created. The data is replaced below instead of truncating
+	// the file first so watchers are sent a single write
+	f, err := m.openFile(name, os.O_WRONLY|os.O_CREATE, perm)
 	if err != nil {
 		return err
 	}
 
-	file, ok := m.fs[name]
-	if !ok {
-		file = &fstest.MapFile{}
-		m.fs[name] = file
-	}
-
-	file.Data = data
-	file.Mode = perm
-
+	// copy the data so the caller can't modify the file
+	f.file.Data = append([]byte{}, data...)
+	f.written()
 	return nil
 }
 
 // Exists implements FS
 func (m *memory) Exists(path string) (bool, error) {
-	name, err := m.path.Normalize(path)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, file, err := m.resolve(path, true)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
-	_, ok := m.fs[name]
-	return ok, nil
+	return file != nil, nil
 }
 
 // Stat implements FS
 func (m *memory) Stat(name string) (fs.FileInfo, error) {
-	f, err := m.Open(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, err := m.open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	return f.Stat()
+	return snapshot(f.name, f.file), nil
 }
 
-// Sub implements FS
+// Sub implements FS. The returned file system supports all FS operations confined to dir
 func (m *memory) Sub(dir string) (fs.FS, error) {
-	return m.fs.Sub(dir)
+	return subDir(m, m.path, dir)
 }
 
 // Mkdir implements MakeDirFS
 func (m *memory) Mkdir(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	fp, err := m.path.Parse(path)
+	op := "mkdir"
+	original := path
+
+	path, base, file, err := m.resolveBase(path, false)
 	if err != nil {
-		return err
+		return &fs.PathError{Op: op, Path: original, Err: err}
+	}
+	if file != nil {
+		return &fs.PathError{Op: op, Path: original, Err: fs.ErrExist}
 	}
-	accumulator := fp.Root()
 
-	// check each ancestor path
-	for i := 0; i < len(fp.Segments); i++ {
-		currentPath := m.path.String(accumulator)
-		currentPath, err = m.path.Normalize(currentPath)
-		if err != nil {
-			return err
-		}
-		_, ok := m.fs[currentPath]
+	// the parent must exist unless this is the root
+	parent := m.path.Dir(path)
+	if parent != path {
+		dir, ok := m.fs[parent]
 		if !ok {
-			return errNotExist(currentPath)
+			return errNotExist(parent)
 		}
-		seg := fp.Segments[i]
-		fpseg, err := m.path.Parse(seg)
-		if err != nil {
-			return err
+		if !dir.Mode.IsDir() {
+			return &fs.PathError{Op: op, Path: original, Err: syscall.ENOTDIR}
+		}
+		if !m.can(dir, accessWrite|accessExecute) {
+			return &fs.PathError{Op: op, Path: original, Err: fs.ErrPermission}
 		}
-		accumulator = accumulator.Join(fpseg)
 	}
 
 	// write the segment
-	m.fs[path] = &fstest.MapFile{
-		Mode: perm | fs.ModeDir,
-	}
+	m.add(path, base, m.newFile(perm&^m.umask|fs.ModeDir))
 
 	return nil
 }
 
 // MkdirAll implements MakeDirFS
 func (m *memory) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op := "mkdir"
 
 	// create all child paths of the current path from the root
 	// so first, grab the root
@@ -353,32 +543,32 @@ func (m *memory) MkdirAll(path string, perm fs.FileMode) error {
 	if err != nil {
 		return err
 	}
+	fp = fp.Clean()
 	accumulator := fp.Root()
 
 	// create each ancestor path
 	for i := 0; i <= len(fp.Segments); i++ {
 		currentPath := m.path.String(accumulator)
-		currentPath, err = m.path.Normalize(currentPath)
+
+		// follow symbolic links so directories are created at the link target
+		key, base, file, err := m.resolveBase(currentPath, true)
 		if err != nil {
-			return err
+			return &fs.PathError{Op: op, Path: currentPath, Err: err}
 		}
-		_, ok := m.fs[currentPath]
 
-		if !ok {
-			m.fs[currentPath] = &fstest.MapFile{
-				Mode: perm | fs.ModeDir,
+		if file == nil {
+			if !m.canModifyParent(key) {
+				return &fs.PathError{Op: op, Path: currentPath, Err: fs.ErrPermission}
 			}
+			m.add(key, base, m.newFile(perm&^m.umask|fs.ModeDir))
+		} else if !file.Mode.IsDir() {
+			return &fs.PathError{Op: op, Path: currentPath, Err: syscall.ENOTDIR}
 		}
 		if i == len(fp.Segments) {
 			break
 		}
 
-		seg := fp.Segments[i]
-		fpseg, err := m.path.Parse(seg)
-		if err != nil {
-			return err
-		}
-		accumulator = accumulator.Join(fpseg)
+		accumulator = child(accumulator, fp.Segments[i])
 	}
 	return nil
 }
@@ -388,11 +578,545 @@ func errNotExist(path string) error {
 }
 
 func (m *memory) Chmod(name string, mode fs.FileMode) error {
-	f, err := m.open(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, file, err := m.lookup("chmod", name, true)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	f.file.Mode = mode
+	if !m.owns(file) {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrPermission}
+	}
+	file.Mode = file.Mode.Type() | mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)
+	m.notify(key, OpChmod)
+	return nil
+}
+
+// Symlink implements SymlinkFS
+func (m *memory) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op := "symlink"
+	key, base, file, err := m.resolveBase(newname, false)
+	if err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	if file != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	if !m.canModifyParent(key) {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	file = m.newFile(fs.ModeSymlink | 0777)
+	file.Data = []byte(oldname)
+	m.add(key, base, file)
+	return nil
+}
+
+// Readlink implements SymlinkFS
+func (m *memory) Readlink(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	op := "readlink"
+	_, file, err := m.lookup(op, name, false)
+	if err != nil {
+		return "", err
+	}
+	if file.Mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return string(file.Data), nil
+}
+
+// Lstat implements SymlinkFS
+func (m *memory) Lstat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, file, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot(m.base(key), file), nil
+}
+
+// Link implements LinkFS
+func (m *memory) Link(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op := "link"
+	_, file, err := m.resolve(oldname, false)
+	if err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	if file == nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if file.Mode.IsDir() {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+
+	key, base, existing, err := m.resolveBase(newname, false)
+	if err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	if existing != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	if !m.canModifyParent(key) {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+
+	// both names share the same file so writes through either are visible in both
+	m.add(key, base, file)
+	if stat, ok := file.Sys.(*MemoryStat); ok {
+		stat.Nlink++
+	}
+	return nil
+}
+
+// Chtimes implements ChtimesFS
+func (m *memory) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, file, err := m.lookup("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	if !m.owns(file) {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrPermission}
+	}
+
+	// zero values leave the corresponding time unchanged
+	if !mtime.IsZero() {
+		file.ModTime = mtime
+	}
+	if stat, ok := file.Sys.(*MemoryStat); ok && !atime.IsZero() {
+		stat.Atime = atime
+	}
+	m.notify(key, OpChmod)
 	return nil
 }
+
+// Chown implements ChownFS
+func (m *memory) Chown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.chown("chown", name, uid, gid, true)
+}
+
+// Lchown implements ChownFS
+func (m *memory) Lchown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.chown("lchown", name, uid, gid, false)
+}
+
+func (m *memory) chown(op string, name string, uid, gid int, follow bool) error {
+	key, file, err := m.lookup(op, name, follow)
+	if err != nil {
+		return err
+	}
+	stat, ok := file.Sys.(*MemoryStat)
+	if !ok {
+		return nil
+	}
+
+	// only root can give files away, owners can change the group to their own group
+	if m.enforce && m.uid != 0 {
+		if stat.Uid != m.uid || (uid != -1 && uid != stat.Uid) || (gid != -1 && gid != m.gid) {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+	}
+
+	// a value of -1 leaves the corresponding id unchanged
+	if uid != -1 {
+		stat.Uid = uid
+	}
+	if gid != -1 {
+		stat.Gid = gid
+	}
+	m.notify(key, OpChmod)
+	return nil
+}
+
+// CreateTemp implements TempFS
+func (m *memory) CreateTemp(dir, pattern string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var file *openFile
+	_, err := m.temp("createtemp", dir, pattern, func(key, name string) {
+		f := m.newFile(0600 &^ m.umask)
+		m.add(key, m.path.Base(name), f)
+		file = &openFile{
+			path:    name,
+			flag:    os.O_RDWR | os.O_CREATE | os.O_EXCL,
+			mu:      &m.mu,
+			written: m.written(key, f),
+			infoFile: infoFile{
+				name: m.base(key),
+				file: f,
+			},
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// MkdirTemp implements TempFS
+func (m *memory) MkdirTemp(dir, pattern string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.temp("mkdirtemp", dir, pattern, func(key, name string) {
+		m.add(key, m.path.Base(name), m.newFile(0700&^m.umask|fs.ModeDir))
+	})
+}
+
+// temp generates a unique name in dir from the pattern and calls create with the key and name
+func (m *memory) temp(op, dir, pattern string, create func(key, name string)) (string, error) {
+	if dir == "" {
+		dir = m.tempDir
+	}
+	if dir == "" {
+		return "", &fs.PathError{Op: op, Path: pattern, Err: fs.ErrInvalid}
+	}
+	if strings.ContainsAny(pattern, string([]rune{rune(filepath.ForwardSlash), rune(m.path.Separator())})) {
+		return "", &fs.PathError{Op: op, Path: pattern, Err: errPatternHasSeparator}
+	}
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	_, d, err := m.resolve(dir, true)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: dir, Err: err}
+	}
+	if d == nil {
+		return "", &fs.PathError{Op: op, Path: dir, Err: fs.ErrNotExist}
+	}
+	if !d.Mode.IsDir() {
+		return "", &fs.PathError{Op: op, Path: dir, Err: syscall.ENOTDIR}
+	}
+	if !m.can(d, accessWrite|accessExecute) {
+		return "", &fs.PathError{Op: op, Path: dir, Err: fs.ErrPermission}
+	}
+
+	for try := 0; try < 10000; try++ {
+		name := m.path.Join(dir, prefix+strconv.FormatUint(uint64(m.random.Uint32()), 10)+suffix)
+		key, file, err := m.resolve(name, false)
+		if err != nil {
+			return "", &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		if file != nil {
+			continue
+		}
+		create(key, name)
+		return name, nil
+	}
+	return "", &fs.PathError{Op: op, Path: m.path.Join(dir, prefix+"*"+suffix), Err: fs.ErrExist}
+}
+
+// add adds the name to the file system and updates the modification time of the parent directory.
+// The base is the final element as it was written which may differ from the key in case
+func (m *memory) add(key string, base string, file *fstest.MapFile) {
+	m.fs[key] = file
+	m.names[key] = base
+	m.touchParent(key)
+	m.notify(key, OpCreate)
+}
+
+// unlink removes the name from the file system and notifies watchers
+func (m *memory) unlink(key string) {
+	if _, ok := m.fs[key]; !ok {
+		return
+	}
+	name := m.display(key)
+	m.release(key)
+	m.notifyName(key, name, OpRemove)
+}
+
+// release removes the name from the file system. The file data is released once the last name is removed
+func (m *memory) release(key string) {
+	file, ok := m.fs[key]
+	if !ok {
+		return
+	}
+	delete(m.fs, key)
+	delete(m.names, key)
+	if stat, ok := file.Sys.(*MemoryStat); ok && stat.Nlink > 0 {
+		stat.Nlink--
+	}
+	m.touchParent(key)
+}
+
+// modified stamps the modification time of the file and notifies watchers of the write
+func (m *memory) modified(key string, file *fstest.MapFile) {
+	file.ModTime = m.clock()
+	m.notify(key, OpWrite)
+}
+
+// written returns a callback that records writes made through a file handle. The callback is called with the lock held
+func (m *memory) written(key string, file *fstest.MapFile) func() {
+	return func() {
+		m.modified(key, file)
+	}
+}
+
+// base returns the final element of key as it was written when the file was created
+func (m *memory) base(key string) string {
+	if base, ok := m.names[key]; ok {
+		return base
+	}
+	return m.path.Base(key)
+}
+
+// touchParent updates the modification time of the directory containing key
+func (m *memory) touchParent(key string) {
+	parent := m.path.Dir(key)
+	if parent == key {
+		return
+	}
+	if dir, ok := m.fs[parent]; ok {
+		dir.ModTime = m.clock()
+	}
+}
+
+// newFile creates a file with a single link stamped with the current time
+func (m *memory) newFile(mode fs.FileMode) *fstest.MapFile {
+	now := m.clock()
+	return &fstest.MapFile{
+		Mode:    mode,
+		ModTime: now,
+		Sys:     &MemoryStat{Nlink: 1, Atime: now, Uid: m.uid, Gid: m.gid},
+	}
+}
+
+// access bits checked against the owner, group or other permission bits of a file
+const (
+	accessExecute fs.FileMode = 1 << iota
+	accessWrite
+	accessRead
+)
+
+// access returns the access bits required to open a file with the flags
+func access(flag int) fs.FileMode {
+	var bits fs.FileMode
+	switch {
+	case flag&os.O_RDWR != 0:
+		bits = accessRead | accessWrite
+	case flag&os.O_WRONLY != 0:
+		bits = accessWrite
+	default:
+		bits = accessRead
+	}
+	if flag&(os.O_TRUNC|os.O_APPEND) != 0 {
+		bits |= accessWrite
+	}
+	return bits
+}
+
+// can reports whether the current user has the requested access to the file. Everything is
+// allowed unless permissions are enforced and the root user is always allowed
+func (m *memory) can(file *fstest.MapFile, access fs.FileMode) bool {
+	if !m.enforce || m.uid == 0 || file == nil {
+		return true
+	}
+	perm := file.Mode.Perm()
+	if stat, ok := file.Sys.(*MemoryStat); ok {
+		switch {
+		case stat.Uid == m.uid:
+			perm >>= 6
+		case stat.Gid == m.gid:
+			perm >>= 3
+		}
+	}
+	return perm&access == access
+}
+
+// canModifyParent reports whether entries can be added to or removed from the directory containing key
+func (m *memory) canModifyParent(key string) bool {
+	return m.can(m.fs[m.path.Dir(key)], accessWrite|accessExecute)
+}
+
+// owns reports whether the current user may change the metadata of the file
+func (m *memory) owns(file *fstest.MapFile) bool {
+	if !m.enforce || m.uid == 0 {
+		return true
+	}
+	stat, ok := file.Sys.(*MemoryStat)
+	return !ok || stat.Uid == m.uid
+}
+
+// isDescendant reports whether key is below the directory dir
+func (m *memory) isDescendant(key, dir string) bool {
+	for {
+		parent := m.path.Dir(key)
+		if parent == key {
+			return false
+		}
+		if parent == dir {
+			return true
+		}
+		key = parent
+	}
+}
+
+// descendants returns the keys of every file below the directory key
+func (m *memory) descendants(key string) []string {
+	var keys []string
+	for k := range m.fs {
+		if m.isDescendant(k, key) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// hasChildren reports whether the directory key contains any entries
+func (m *memory) hasChildren(key string) bool {
+	for k := range m.fs {
+		if k != key && m.path.Dir(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup resolves name and returns an error if it does not exist
+func (m *memory) lookup(op string, name string, follow bool) (string, *fstest.MapFile, error) {
+	key, file, err := m.resolve(name, follow)
+	if err != nil {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if file == nil {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return key, file, nil
+}
+
+// resolve walks name one segment at a time following symbolic links and returns the
+// normalized key of the final element along with its file. The file is nil if the final
+// element does not exist. When follow is false a symbolic link in the final element is not followed.
+// Every element before the final one must be a directory unless the file system is lenient.
+func (m *memory) resolve(name string, follow bool) (string, *fstest.MapFile, error) {
+	key, _, file, err := m.resolveBase(name, follow)
+	return key, file, err
+}
+
+// resolveBase resolves name like resolve and also returns the final element as it was written.
+// The base is stored when a file is created so names keep their case when the provider ignores case
+func (m *memory) resolveBase(name string, follow bool) (string, string, *fstest.MapFile, error) {
+	fp, err := m.path.Parse(name)
+	if err != nil {
+		return "", "", nil, err
+	}
+	fp = fp.Clean()
+
+	current := fp.Root()
+	remaining := append([]string{}, fp.Segments...)
+	links := 0
+
+	for len(remaining) > 0 {
+		segment := remaining[0]
+		remaining = remaining[1:]
+
+		switch segment {
+		case filepath.CurrentDirectory, filepath.EmptyDirectory:
+			continue
+		case filepath.ParentDirectory:
+			current = parent(current)
+			continue
+		}
+
+		next := child(current, segment)
+		key, err := m.key(next)
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		file, ok := m.fs[key]
+
+		// every component before the last must be an existing directory
+		if len(remaining) > 0 {
+			switch {
+			case !ok && !m.lenient:
+				return "", "", nil, fs.ErrNotExist
+			case ok && file.Mode&fs.ModeSymlink == 0 && !file.Mode.IsDir():
+				return "", "", nil, syscall.ENOTDIR
+			}
+		}
+
+		if !ok || file.Mode&fs.ModeSymlink == 0 || (!follow && len(remaining) == 0) {
+			// directories must be searchable to reach the entries below them
+			if ok && file.Mode.IsDir() && len(remaining) > 0 && !m.can(file, accessExecute) {
+				return "", "", nil, fs.ErrPermission
+			}
+			current = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", "", nil, syscall.ELOOP
+		}
+
+		// absolute targets restart the walk from their root, relative targets
+		// are evaluated from the directory containing the link
+		target, err := m.path.Parse(string(file.Data))
+		if err != nil {
+			return "", "", nil, err
+		}
+		if target.IsAbs() {
+			current = target.Root()
+		}
+		remaining = append(append([]string{}, target.Segments...), remaining...)
+	}
+
+	key, err := m.key(current)
+	if err != nil {
+		return "", "", nil, err
+	}
+	base := m.path.Base(m.path.String(current))
+	return key, base, m.fs[key], nil
+}
+
+// key returns the map key of the file path
+func (m *memory) key(fp filepath.FilePath) (string, error) {
+	if fp.IsRel() && len(fp.Segments) == 0 {
+		fp.Segments = []string{filepath.CurrentDirectory}
+	}
+	return m.path.Normalize(m.path.String(fp))
+}
+
+// child returns a copy of fp with the segment appended
+func child(fp filepath.FilePath, segment string) filepath.FilePath {
+	segments := make([]string, 0, len(fp.Segments)+1)
+	segments = append(segments, fp.Segments...)
+	fp.Segments = append(segments, segment)
+	return fp
+}
+
+// parent returns a copy of fp with the last segment removed
+func parent(fp filepath.FilePath) filepath.FilePath {
+	last := len(fp.Segments) - 1
+	switch {
+	case last >= 0 && fp.Segments[last] != filepath.ParentDirectory:
+		fp.Segments = fp.Segments[:last:last]
+	case fp.IsRel():
+		fp = child(fp, filepath.ParentDirectory)
+	}
+	return fp
+}
@@ -1,12 +1,18 @@
 package fs_test
 
 import (
+	iofs "io/fs"
+	gos "os"
+	"runtime"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/patrickhuber/go-cross/filepath"
 	"github.com/patrickhuber/go-cross/fs"
 	"github.com/patrickhuber/go-cross/os"
 	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMemoryMkdirCreatesRootUnix(t *testing.T) {
@@ -122,6 +128,543 @@ func TestCanChmod(t *testing.T) {
 		TestCanChangePermission(t, "/opt/fake/folder/test.txt")
 }
 
+func TestMemorySymlink(t *testing.T) {
+	newConformance(platform.Linux).
+		TestSymlink(t, "/gran/parent/child", "target.txt", "link.txt")
+}
+
+func TestMemorySymlinkWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestSymlink(t, `c:\gran\parent\child`, `..\child\target.txt`, "link.txt")
+}
+
+func TestMemorySymlinkDirectory(t *testing.T) {
+	newConformance(platform.Linux).
+		TestSymlinkDirectory(t, "/gran/parent", "child", "link", []file{
+			{"one.txt", []byte("one")},
+			{"two.txt", []byte("two")},
+		})
+}
+
+func TestMemorySymlinkDirectoryWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestSymlinkDirectory(t, `c:\gran\parent`, "child", "link", []file{
+			{"one.txt", []byte("one")},
+			{"two.txt", []byte("two")},
+		})
+}
+
+func TestMemorySymlinkLoop(t *testing.T) {
+	newConformance(platform.Linux).
+		TestSymlinkLoop(t, "/gran/parent/child")
+}
+
+func TestMemoryHardLink(t *testing.T) {
+	newConformance(platform.Linux).
+		TestHardLink(t, "/gran/parent/child", "original.txt", "link.txt")
+}
+
+func TestMemoryHardLinkCount(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Windows))
+	folder := `c:\gran\parent\child`
+	original := path.Join(folder, "original.txt")
+	link := path.Join(folder, "link.txt")
+
+	require.NoError(t, fsys.MkdirAll(folder, 0775))
+	require.NoError(t, fsys.WriteFile(original, []byte("data"), 0644))
+	require.NoError(t, fsys.Link(original, link))
+
+	nlink := func(name string) uint64 {
+		info, err := fsys.Stat(name)
+		require.NoError(t, err)
+		stat, ok := info.Sys().(*fs.MemoryStat)
+		require.True(t, ok)
+		return stat.Nlink
+	}
+	require.Equal(t, uint64(2), nlink(original))
+	require.Equal(t, uint64(2), nlink(link))
+
+	require.NoError(t, fsys.Remove(original))
+	require.Equal(t, uint64(1), nlink(link))
+}
+
+func TestMemoryChtimes(t *testing.T) {
+	newConformance(platform.Linux).
+		TestChtimes(t, "/gran/parent/child/file.txt")
+}
+
+func TestMemoryClockStampsModTime(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	advance := func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	path := filepath.NewProviderFromOS(newOS(platform.Linux))
+	fsys := fs.NewMemory(path, fs.WithClock(clock))
+
+	modTime := func(name string) time.Time {
+		info, err := fsys.Stat(name)
+		require.NoError(t, err)
+		return info.ModTime()
+	}
+
+	require.NoError(t, fsys.MkdirAll("/gran/parent", 0775))
+	require.Equal(t, now, modTime("/gran/parent"))
+
+	expected := advance()
+	require.NoError(t, fsys.WriteFile("/gran/parent/one.txt", []byte("one"), 0644))
+	require.Equal(t, expected, modTime("/gran/parent/one.txt"))
+	require.Equal(t, expected, modTime("/gran/parent"))
+
+	expected = advance()
+	f, err := fsys.OpenFile("/gran/parent/one.txt", gos.O_WRONLY|gos.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(" more"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, expected, modTime("/gran/parent/one.txt"))
+
+	created := modTime("/gran/parent/one.txt")
+	expected = advance()
+	require.NoError(t, fsys.Mkdir("/gran/parent/child", 0775))
+	require.Equal(t, expected, modTime("/gran/parent/child"))
+
+	expected = advance()
+	require.NoError(t, fsys.Rename("/gran/parent/one.txt", "/gran/parent/child/one.txt"))
+	require.Equal(t, expected, modTime("/gran/parent"))
+	require.Equal(t, expected, modTime("/gran/parent/child"))
+	require.Equal(t, created, modTime("/gran/parent/child/one.txt"))
+}
+
+func TestMemoryChown(t *testing.T) {
+	path := filepath.NewProviderFromOS(newOS(platform.Linux))
+	fsys := fs.NewMemory(path, fs.WithUser(1000, 1000))
+
+	owner := func(info iofs.FileInfo) (int, int) {
+		stat, ok := info.Sys().(*fs.MemoryStat)
+		require.True(t, ok)
+		return stat.Uid, stat.Gid
+	}
+
+	require.NoError(t, fsys.MkdirAll("/opt/app", 0775))
+	require.NoError(t, fsys.WriteFile("/opt/app/config.yaml", []byte("test"), 0644))
+	require.NoError(t, fsys.Symlink("/opt/app/config.yaml", "/opt/app/link.yaml"))
+
+	info, err := fsys.Stat("/opt/app/config.yaml")
+	require.NoError(t, err)
+	uid, gid := owner(info)
+	require.Equal(t, 1000, uid)
+	require.Equal(t, 1000, gid)
+
+	// chown follows the link
+	require.NoError(t, fsys.Chown("/opt/app/link.yaml", 0, -1))
+	info, err = fsys.Stat("/opt/app/config.yaml")
+	require.NoError(t, err)
+	uid, gid = owner(info)
+	require.Equal(t, 0, uid)
+	require.Equal(t, 1000, gid)
+
+	// lchown changes the link itself
+	require.NoError(t, fsys.Lchown("/opt/app/link.yaml", 2000, 2000))
+	info, err = fsys.Lstat("/opt/app/link.yaml")
+	require.NoError(t, err)
+	uid, gid = owner(info)
+	require.Equal(t, 2000, uid)
+	require.Equal(t, 2000, gid)
+
+	info, err = fsys.Stat("/opt/app/config.yaml")
+	require.NoError(t, err)
+	uid, gid = owner(info)
+	require.Equal(t, 0, uid)
+	require.Equal(t, 1000, gid)
+
+	err = fsys.Chown("/opt/app/missing.yaml", 0, 0)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func TestMemoryCreateTemp(t *testing.T) {
+	newConformance(platform.Linux).
+		TestCreateTemp(t, "/tmp", "test-*.txt")
+}
+
+func TestMemoryCreateTempWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestCreateTemp(t, os.FakeWindowsTempDirectory, "test")
+}
+
+func TestMemoryMkdirTemp(t *testing.T) {
+	newConformance(platform.Linux).
+		TestMkdirTemp(t, "/tmp", "test-*")
+}
+
+func TestMemoryTempNamesAreDeterministic(t *testing.T) {
+	names := func(seed int64) []string {
+		path := filepath.NewProviderFromOS(newOS(platform.Linux))
+		fsys := fs.NewMemory(path, fs.WithSeed(seed), fs.WithTempDir("/tmp"))
+		require.NoError(t, fsys.MkdirAll("/tmp", 0775))
+
+		var result []string
+		for i := 0; i < 3; i++ {
+			f, err := fsys.CreateTemp("", "test-*.txt")
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			result = append(result, f.Name())
+		}
+		dir, err := fsys.MkdirTemp("", "dir-*")
+		require.NoError(t, err)
+		return append(result, dir)
+	}
+	require.Equal(t, names(42), names(42))
+	require.NotEqual(t, names(42), names(7))
+}
+
+func TestMemoryCreateTempRequiresTempDir(t *testing.T) {
+	fsys, _ := newMemory(newOS(platform.Linux))
+	_, err := fsys.CreateTemp("", "test-*.txt")
+	require.Error(t, err)
+}
+
+func TestMemorySub(t *testing.T) {
+	newConformance(platform.Linux).
+		TestSub(t, "/working")
+}
+
+func TestMemorySubWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestSub(t, `c:\working`)
+}
+
+func TestOSSub(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestSub(t, t.TempDir())
+}
+
+func newPermissions(t *testing.T, uid, gid int) fs.FS {
+	path := filepath.NewProviderFromOS(newOS(platform.Linux))
+	fsys := fs.NewMemory(path, fs.WithPermissions(), fs.WithUser(uid, gid))
+
+	// the user owns every file so the owner bits are checked, restrict them after the tree is created
+	require.NoError(t, fsys.MkdirAll("/data/private", 0755))
+	require.NoError(t, fsys.MkdirAll("/data/locked", 0755))
+	require.NoError(t, fsys.MkdirAll("/data/unlisted", 0755))
+	require.NoError(t, fsys.WriteFile("/data/private/secret.txt", []byte("secret"), 0644))
+	require.NoError(t, fsys.WriteFile("/data/locked/readonly.txt", []byte("readonly"), 0444))
+	require.NoError(t, fsys.WriteFile("/data/writeonly.txt", []byte("writeonly"), 0222))
+	require.NoError(t, fsys.Chmod("/data/private", 0600))
+	require.NoError(t, fsys.Chmod("/data/locked", 0555))
+	require.NoError(t, fsys.Chmod("/data/unlisted", 0300))
+	return fsys
+}
+
+func TestMemoryPermissions(t *testing.T) {
+	type test struct {
+		name   string
+		action func(fs.FS) error
+	}
+	tests := []test{
+		{"read_through_unsearchable_dir", func(fsys fs.FS) error { _, err := fsys.ReadFile("/data/private/secret.txt"); return err }},
+		{"stat_through_unsearchable_dir", func(fsys fs.FS) error { _, err := fsys.Stat("/data/private/secret.txt"); return err }},
+		{"read_write_only_file", func(fsys fs.FS) error { _, err := fsys.ReadFile("/data/writeonly.txt"); return err }},
+		{"open_write_only_file", func(fsys fs.FS) error { _, err := fsys.Open("/data/writeonly.txt"); return err }},
+		{"write_read_only_file", func(fsys fs.FS) error { return fsys.WriteFile("/data/locked/readonly.txt", nil, 0644) }},
+		{"open_file_read_only_file_for_write", func(fsys fs.FS) error {
+			_, err := fsys.OpenFile("/data/locked/readonly.txt", gos.O_WRONLY, 0)
+			return err
+		}},
+		{"create_read_only_file", func(fsys fs.FS) error { _, err := fsys.Create("/data/locked/readonly.txt"); return err }},
+		{"write_in_read_only_dir", func(fsys fs.FS) error { return fsys.WriteFile("/data/locked/new.txt", nil, 0644) }},
+		{"open_file_create_in_read_only_dir", func(fsys fs.FS) error {
+			_, err := fsys.OpenFile("/data/locked/new.txt", gos.O_WRONLY|gos.O_CREATE, 0644)
+			return err
+		}},
+		{"mkdir_in_read_only_dir", func(fsys fs.FS) error { return fsys.Mkdir("/data/locked/dir", 0755) }},
+		{"mkdir_all_in_read_only_dir", func(fsys fs.FS) error { return fsys.MkdirAll("/data/locked/dir/sub", 0755) }},
+		{"remove_from_read_only_dir", func(fsys fs.FS) error { return fsys.Remove("/data/locked/readonly.txt") }},
+		{"rename_from_read_only_dir", func(fsys fs.FS) error { return fsys.Rename("/data/locked/readonly.txt", "/data/moved.txt") }},
+		{"symlink_in_read_only_dir", func(fsys fs.FS) error { return fsys.Symlink("readonly.txt", "/data/locked/link.txt") }},
+		{"read_dir_without_read", func(fsys fs.FS) error { _, err := fsys.ReadDir("/data/unlisted"); return err }},
+		{"create_temp_in_read_only_dir", func(fsys fs.FS) error { _, err := fsys.CreateTemp("/data/locked", "tmp"); return err }},
+		{"chown_to_other_user", func(fsys fs.FS) error { return fsys.Chown("/data/writeonly.txt", 2000, -1) }},
+		{"chown_to_other_group", func(fsys fs.FS) error { return fsys.Chown("/data/writeonly.txt", -1, 2000) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := newPermissions(t, 1000, 1000)
+			require.ErrorIs(t, test.action(fsys), iofs.ErrPermission)
+
+			// root bypasses the checks
+			root := newPermissions(t, 0, 0)
+			require.NoError(t, test.action(root))
+		})
+	}
+}
+
+func TestMemoryPermissionsAllowed(t *testing.T) {
+	fsys := newPermissions(t, 1000, 1000)
+
+	content, err := fsys.ReadFile("/data/locked/readonly.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("readonly"), content)
+
+	require.NoError(t, fsys.WriteFile("/data/writeonly.txt", []byte("changed"), 0644))
+	require.NoError(t, fsys.WriteFile("/data/new.txt", []byte("new"), 0644))
+	require.NoError(t, fsys.Chmod("/data/private", 0700))
+
+	content, err = fsys.ReadFile("/data/private/secret.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), content)
+}
+
+func TestMemoryUmask(t *testing.T) {
+	path := filepath.NewProviderFromOS(newOS(platform.Linux))
+	fsys := fs.NewMemory(path, fs.WithUmask(0022))
+
+	require.NoError(t, fsys.Mkdir("/", 0755))
+	require.NoError(t, fsys.Mkdir("/dir", 0777))
+	require.NoError(t, fsys.MkdirAll("/all/sub", 0777))
+	require.NoError(t, fsys.WriteFile("/dir/write.txt", nil, 0666))
+
+	f, err := fsys.OpenFile("/dir/open.txt", gos.O_WRONLY|gos.O_CREATE, 0666)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = fsys.Create("/dir/create.txt")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	expected := map[string]iofs.FileMode{
+		"/dir":            iofs.ModeDir | 0755,
+		"/all":            iofs.ModeDir | 0755,
+		"/all/sub":        iofs.ModeDir | 0755,
+		"/dir/write.txt":  0644,
+		"/dir/open.txt":   0644,
+		"/dir/create.txt": 0644,
+	}
+	for name, mode := range expected {
+		info, err := fsys.Stat(name)
+		require.NoError(t, err)
+		require.Equal(t, mode, info.Mode(), name)
+	}
+
+	// the umask does not apply to explicit changes
+	require.NoError(t, fsys.Chmod("/dir/write.txt", 0666))
+	info, err := fsys.Stat("/dir/write.txt")
+	require.NoError(t, err)
+	require.Equal(t, iofs.FileMode(0666), info.Mode())
+}
+
+func TestMemoryHandlesShareData(t *testing.T) {
+	newConformance(platform.Linux).
+		TestHandlesShareData(t, "/gran/parent/child/file.txt")
+}
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	newConformance(platform.Linux).
+		TestConcurrentAccess(t, "/gran/parent/child")
+}
+
+func TestMemoryConcurrentAccessWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestConcurrentAccess(t, `c:\gran\parent\child`)
+}
+
+func TestOSHandlesShareData(t *testing.T) {
+	path := filepath.NewProviderFromOS(os.New())
+	NewConformanceWithProvider(fs.New(), path).
+		TestHandlesShareData(t, path.Join(t.TempDir(), "file.txt"))
+}
+
+func TestOSConcurrentAccess(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestConcurrentAccess(t, t.TempDir())
+}
+
+func TestMemoryOpenFileFlags(t *testing.T) {
+	newConformance(platform.Linux).
+		TestOpenFileFlags(t, "/gran/parent/child")
+}
+
+func TestMemoryOpenFileFlagsWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestOpenFileFlags(t, `c:\gran\parent\child`)
+}
+
+func TestOSOpenFileFlags(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestOpenFileFlags(t, t.TempDir())
+}
+
+func TestMemoryWriteDirectory(t *testing.T) {
+	newConformance(platform.Linux).
+		TestWriteDirectory(t, "/gran/parent/child")
+}
+
+func TestMemoryWriteDirectoryWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestWriteDirectory(t, `c:\gran\parent\child`)
+}
+
+func TestOSWriteDirectory(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestWriteDirectory(t, t.TempDir())
+}
+
+func TestMemoryRenameDirectory(t *testing.T) {
+	newConformance(platform.Linux).
+		TestRenameDirectory(t, "/gran/parent/child")
+}
+
+func TestMemoryRenameDirectoryWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestRenameDirectory(t, `c:\gran\parent\child`)
+}
+
+func TestMemoryRenameOverExisting(t *testing.T) {
+	newConformance(platform.Linux).
+		TestRenameOverExisting(t, "/gran/parent/child")
+}
+
+func TestMemoryRenameOverExistingWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestRenameOverExisting(t, `c:\gran\parent\child`)
+}
+
+func TestMemoryRemove(t *testing.T) {
+	newConformance(platform.Linux).
+		TestRemove(t, "/gran/parent/child")
+}
+
+func TestMemoryRemoveWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestRemove(t, `c:\gran\parent\child`)
+}
+
+func TestMemoryRemoveAll(t *testing.T) {
+	newConformance(platform.Linux).
+		TestRemoveAll(t, "/gran/parent/child")
+}
+
+func TestMemoryRemoveAllWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestRemoveAll(t, `c:\gran\parent\child`)
+}
+
+func TestOSRenameDirectory(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestRenameDirectory(t, t.TempDir())
+}
+
+func TestOSRenameOverExisting(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestRenameOverExisting(t, t.TempDir())
+}
+
+func TestOSRemove(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestRemove(t, t.TempDir())
+}
+
+func TestOSRemoveAll(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestRemoveAll(t, t.TempDir())
+}
+
+func TestMemoryCreateRequiresParent(t *testing.T) {
+	newConformance(platform.Linux).
+		TestCreateRequiresParent(t, "/gran/parent/child")
+}
+
+func TestMemoryCreateRequiresParentWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestCreateRequiresParent(t, `c:\gran\parent\child`)
+}
+
+func TestOSCreateRequiresParent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows reports a missing path instead of ENOTDIR")
+	}
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestCreateRequiresParent(t, t.TempDir())
+}
+
+func TestMemoryLenientParents(t *testing.T) {
+	path := filepath.NewProviderFromOS(newOS(platform.Linux))
+	fsys := fs.NewMemory(path, fs.WithLenientParents())
+
+	require.NoError(t, fsys.WriteFile("/missing/file.txt", []byte("file"), 0644))
+	content, err := fsys.ReadFile("/missing/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("file"), content)
+
+	// files still can't contain other files
+	err = fsys.WriteFile("/missing/file.txt/child.txt", []byte("child"), 0644)
+	require.ErrorIs(t, err, syscall.ENOTDIR)
+}
+
+func TestMemoryGlob(t *testing.T) {
+	newConformance(platform.Linux).
+		TestGlob(t, "/gran/parent/child")
+}
+
+func TestMemoryGlobWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestGlob(t, `c:\gran\parent\child`)
+}
+
+func TestMemoryReadDirSorted(t *testing.T) {
+	newConformance(platform.Linux).
+		TestReadDirSorted(t, "/gran/parent/child")
+}
+
+func TestMemoryReadDirSortedWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestReadDirSorted(t, `c:\gran\parent\child`)
+}
+
+func TestMemoryGlobIgnoresCaseOnWindows(t *testing.T) {
+	fsys, _ := newMemory(newOS(platform.Windows))
+	require.NoError(t, fsys.MkdirAll(`c:\data`, 0775))
+	require.NoError(t, fsys.WriteFile(`c:\data\file.txt`, nil, 0644))
+
+	matches, err := fsys.Glob(`C:\DATA\*.TXT`)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+
+	matches, err = fsys.Glob(`c:/data/*.txt`)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+}
+
+func TestOSGlob(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestGlob(t, t.TempDir())
+}
+
+func TestOSReadDirSorted(t *testing.T) {
+	NewConformanceWithProvider(fs.New(), filepath.NewProviderFromOS(os.New())).
+		TestReadDirSorted(t, t.TempDir())
+}
+
+func TestMemoryPreservesCase(t *testing.T) {
+	newConformance(platform.Darwin).
+		TestPreservesCase(t, "/Users/Fake")
+}
+
+func TestMemoryPreservesCaseWindows(t *testing.T) {
+	newConformance(platform.Windows).
+		TestPreservesCase(t, `c:\Users\Fake`)
+}
+
+func TestMemoryCaseSensitiveOnLinux(t *testing.T) {
+	fsys, _ := newMemory(newOS(platform.Linux))
+	require.NoError(t, fsys.MkdirAll("/data", 0775))
+	require.NoError(t, fsys.WriteFile("/data/file.txt", []byte("lower"), 0644))
+	require.NoError(t, fsys.WriteFile("/data/FILE.txt", []byte("upper"), 0644))
+	require.Equal(t, []string{"FILE.txt", "file.txt"}, names(t, fsys, "/data"))
+}
+
 func newMemory(o os.OS) (fs.FS, filepath.Provider) {
 	path := filepath.NewProviderFromOS(o)
 	fs := fs.NewMemory(path)
@@ -4,13 +4,21 @@ import (
 	"errors"
 	iofs "io/fs"
 	"os"
+	gofilepath "path/filepath"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	cross "github.com/patrickhuber/go-cross/os"
 )
 
 type osfs struct {
+	path filepath.Provider
 }
 
 func New() FS {
-	return &osfs{}
+	return &osfs{
+		path: filepath.NewProviderFromOS(cross.New()),
+	}
 }
 
 // OpenFile implements FS
@@ -44,8 +52,8 @@ func (*osfs) RemoveAll(path string) error {
 }
 
 // Glob implements FS
-func (o *osfs) Glob(pattern string) ([]string, error) {
-	return iofs.Glob(o, pattern)
+func (*osfs) Glob(pattern string) ([]string, error) {
+	return gofilepath.Glob(pattern)
 }
 
 // ReadDir implements FS
@@ -81,9 +89,9 @@ func (*osfs) Exists(path string) (bool, error) {
 	return false, err
 }
 
-// Sub implements FS
+// Sub implements FS. The returned file system supports all FS operations confined to dir
 func (o *osfs) Sub(dir string) (iofs.FS, error) {
-	return iofs.Sub(o, dir)
+	return subDir(o, o.path, dir)
 }
 
 // Mkdir implements MakeDirFS
@@ -100,3 +108,53 @@ func (o *osfs) MkdirAll(path string, perm iofs.FileMode) error {
 func (o *osfs) Chmod(name string, perm iofs.FileMode) error {
 	return os.Chmod(name, perm)
 }
+
+// Symlink implements SymlinkFS
+func (o *osfs) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+// Readlink implements SymlinkFS
+func (o *osfs) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+// Lstat implements SymlinkFS
+func (o *osfs) Lstat(name string) (iofs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+// Link implements LinkFS
+func (o *osfs) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+// Chtimes implements ChtimesFS
+func (o *osfs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Chown implements ChownFS
+func (o *osfs) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+// Lchown implements ChownFS
+func (o *osfs) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+// CreateTemp implements TempFS
+func (o *osfs) CreateTemp(dir, pattern string) (File, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+// MkdirTemp implements TempFS
+func (o *osfs) MkdirTemp(dir, pattern string) (string, error) {
+	return os.MkdirTemp(dir, pattern)
+}
+
+// Watch implements WatchFS
+func (o *osfs) Watch(name string, recursive bool) (Watcher, error) {
+	return watch(name, recursive)
+}
@@ -0,0 +1,558 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+type overlay struct {
+	base  FS
+	upper FS
+	path  filepath.Provider
+
+	// mu guards the whiteouts and opaque directories
+	mu sync.RWMutex
+	// whiteouts hides the path and its descendants in the base layer
+	whiteouts map[string]struct{}
+	// opaque directories were removed and recreated so their base layer children are hidden
+	opaque map[string]struct{}
+}
+
+// NewOverlay creates a file system that reads through a writable upper layer to a base layer. All changes
+// are written to the upper layer, files are copied up from the base before they are modified and removals
+// are recorded as whiteouts that hide the base entry. The base layer is never modified.
+func NewOverlay(base FS, upper FS, path filepath.Provider) FS {
+	return &overlay{
+		base:      base,
+		upper:     upper,
+		path:      path,
+		whiteouts: map[string]struct{}{},
+		opaque:    map[string]struct{}{},
+	}
+}
+
+// Open implements FS
+func (o *overlay) Open(name string) (iofs.File, error) {
+	if o.inUpper(name) {
+		return o.upper.Open(name)
+	}
+	if o.hidden(name) {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrNotExist}
+	}
+	return o.base.Open(name)
+}
+
+// OpenFile implements FS
+func (o *overlay) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		if o.inUpper(name) {
+			return o.upper.OpenFile(name, flag, perm)
+		}
+		if o.hidden(name) {
+			return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrNotExist}
+		}
+		return o.base.OpenFile(name, flag, perm)
+	}
+
+	// truncated files don't need their content copied up
+	var err error
+	if flag&os.O_TRUNC != 0 {
+		err = o.copyUpParent(name)
+	} else {
+		err = o.copyUp(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := o.upper.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	o.unhide(name, false)
+	return f, nil
+}
+
+// Create implements FS
+func (o *overlay) Create(name string) (File, error) {
+	if err := o.copyUpParent(name); err != nil {
+		return nil, err
+	}
+	f, err := o.upper.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	o.unhide(name, false)
+	return f, nil
+}
+
+// Rename implements FS. Like os.Rename an existing directory is never replaced and a directory can't replace a file
+func (o *overlay) Rename(oldName, newName string) error {
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: err}
+	}
+
+	info, err := o.Lstat(oldName)
+	if err != nil {
+		return linkErr(errors.Unwrap(err))
+	}
+	if o.key(oldName) == o.key(newName) {
+		return nil
+	}
+
+	// check the destination before anything is removed or copied
+	existing, err := o.Lstat(newName)
+	switch {
+	case err == nil && existing.IsDir():
+		return linkErr(syscall.EEXIST)
+	case err == nil && info.IsDir():
+		return linkErr(syscall.ENOTDIR)
+	case err != nil && !errors.Is(err, iofs.ErrNotExist):
+		return linkErr(errors.Unwrap(err))
+	}
+	if info.IsDir() && within(o.path, oldName, newName) {
+		return linkErr(syscall.EINVAL)
+	}
+	parent, err := o.Stat(o.path.Dir(newName))
+	if err != nil {
+		return linkErr(errors.Unwrap(err))
+	}
+	if !parent.IsDir() {
+		return linkErr(syscall.ENOTDIR)
+	}
+
+	// directories from the base are copied as a whole and then removed
+	if info.IsDir() && o.inBase(oldName) {
+		if err := Copy(Tree{FS: o, Path: o.path, Dir: oldName}, Tree{FS: o, Path: o.path, Dir: newName}); err != nil {
+			return err
+		}
+		return o.RemoveAll(oldName)
+	}
+
+	if err := o.copyUp(oldName); err != nil {
+		return err
+	}
+	if err := o.copyUpParent(newName); err != nil {
+		return err
+	}
+	if err := o.upper.Rename(oldName, newName); err != nil {
+		return err
+	}
+	o.unhide(newName, info.IsDir())
+	o.whiteout(oldName)
+	return nil
+}
+
+// Remove implements FS
+func (o *overlay) Remove(name string) error {
+	info, err := o.Lstat(name)
+	if err != nil {
+		return changeOp(err, "remove")
+	}
+	if info.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &iofs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	if o.inUpper(name) {
+		if err := o.upper.Remove(name); err != nil {
+			return err
+		}
+	}
+	o.whiteout(name)
+	return nil
+}
+
+// RemoveAll implements FS
+func (o *overlay) RemoveAll(name string) error {
+	if err := o.upper.RemoveAll(name); err != nil {
+		return err
+	}
+	o.whiteout(name)
+	return nil
+}
+
+// Glob implements FS
+func (o *overlay) Glob(pattern string) ([]string, error) {
+	return globFS(o, o.path, pattern)
+}
+
+// ReadDir implements FS. The entries of both layers are merged with upper entries taking precedence and whiteouts hidden
+func (o *overlay) ReadDir(name string) ([]iofs.DirEntry, error) {
+	info, err := o.Stat(name)
+	if err != nil {
+		return nil, changeOp(err, "readdir")
+	}
+	if !info.IsDir() {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	merged := map[string]iofs.DirEntry{}
+	if !o.hidden(name) && !o.isOpaque(name) {
+		entries, err := o.base.ReadDir(name)
+		if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if o.hidden(o.path.Join(name, entry.Name())) {
+				continue
+			}
+			merged[entry.Name()] = entry
+		}
+	}
+
+	entries, err := o.upper.ReadDir(name)
+	if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		merged[entry.Name()] = entry
+	}
+
+	result := make([]iofs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+// ReadFile implements FS
+func (o *overlay) ReadFile(name string) ([]byte, error) {
+	if o.inUpper(name) {
+		return o.upper.ReadFile(name)
+	}
+	if o.hidden(name) {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrNotExist}
+	}
+	return o.base.ReadFile(name)
+}
+
+// WriteFile implements FS
+func (o *overlay) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	if err := o.copyUpParent(name); err != nil {
+		return err
+	}
+	if err := o.upper.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+	o.unhide(name, false)
+	return nil
+}
+
+// Exists implements FS
+func (o *overlay) Exists(name string) (bool, error) {
+	if o.inUpper(name) {
+		return o.upper.Exists(name)
+	}
+	if o.hidden(name) {
+		return false, nil
+	}
+	return o.base.Exists(name)
+}
+
+// Stat implements FS
+func (o *overlay) Stat(name string) (iofs.FileInfo, error) {
+	if o.inUpper(name) {
+		return o.upper.Stat(name)
+	}
+	if o.hidden(name) {
+		return nil, &iofs.PathError{Op: "stat", Path: name, Err: iofs.ErrNotExist}
+	}
+	return o.base.Stat(name)
+}
+
+// Sub implements FS. The returned file system supports all FS operations confined to dir
+func (o *overlay) Sub(dir string) (iofs.FS, error) {
+	return subDir(o, o.path, dir)
+}
+
+// Mkdir implements MakeDirFS
+func (o *overlay) Mkdir(name string, perm iofs.FileMode) error {
+	if ok, _ := o.Exists(name); ok {
+		return &iofs.PathError{Op: "mkdir", Path: name, Err: iofs.ErrExist}
+	}
+	if err := o.copyUpParent(name); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(name, perm); err != nil {
+		return err
+	}
+	o.unhide(name, true)
+	return nil
+}
+
+// MkdirAll implements MakeDirFS
+func (o *overlay) MkdirAll(name string, perm iofs.FileMode) error {
+	info, err := o.Stat(name)
+	if err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &iofs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	// create the parent first so existing base directories are copied up with their own mode
+	parent := o.path.Dir(name)
+	if parent != name {
+		if err := o.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	return o.Mkdir(name, perm)
+}
+
+// Chmod implements ChmodFS
+func (o *overlay) Chmod(name string, mode iofs.FileMode) error {
+	if err := o.copyUp(name); err != nil {
+		return err
+	}
+	return o.upper.Chmod(name, mode)
+}
+
+// Symlink implements SymlinkFS
+func (o *overlay) Symlink(oldname, newname string) error {
+	if ok, _ := o.lexists(newname); ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: iofs.ErrExist}
+	}
+	if err := o.copyUpParent(newname); err != nil {
+		return err
+	}
+	if err := o.upper.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	o.unhide(newname, false)
+	return nil
+}
+
+// Readlink implements SymlinkFS
+func (o *overlay) Readlink(name string) (string, error) {
+	if o.inUpper(name) {
+		return o.upper.Readlink(name)
+	}
+	if o.hidden(name) {
+		return "", &iofs.PathError{Op: "readlink", Path: name, Err: iofs.ErrNotExist}
+	}
+	return o.base.Readlink(name)
+}
+
+// Lstat implements SymlinkFS
+func (o *overlay) Lstat(name string) (iofs.FileInfo, error) {
+	if o.inUpper(name) {
+		return o.upper.Lstat(name)
+	}
+	if o.hidden(name) {
+		return nil, &iofs.PathError{Op: "lstat", Path: name, Err: iofs.ErrNotExist}
+	}
+	return o.base.Lstat(name)
+}
+
+// Link implements LinkFS
+func (o *overlay) Link(oldname, newname string) error {
+	if ok, _ := o.lexists(newname); ok {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: iofs.ErrExist}
+	}
+	if err := o.copyUp(oldname); err != nil {
+		return err
+	}
+	if err := o.copyUpParent(newname); err != nil {
+		return err
+	}
+	if err := o.upper.Link(oldname, newname); err != nil {
+		return err
+	}
+	o.unhide(newname, false)
+	return nil
+}
+
+// Chtimes implements ChtimesFS
+func (o *overlay) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := o.copyUp(name); err != nil {
+		return err
+	}
+	return o.upper.Chtimes(name, atime, mtime)
+}
+
+// Chown implements ChownFS
+func (o *overlay) Chown(name string, uid, gid int) error {
+	if err := o.copyUp(name); err != nil {
+		return err
+	}
+	return o.upper.Chown(name, uid, gid)
+}
+
+// Lchown implements ChownFS
+func (o *overlay) Lchown(name string, uid, gid int) error {
+	if err := o.copyUp(name); err != nil {
+		return err
+	}
+	return o.upper.Lchown(name, uid, gid)
+}
+
+// CreateTemp implements TempFS
+func (o *overlay) CreateTemp(dir, pattern string) (File, error) {
+	if dir != "" {
+		if err := o.copyUp(dir); err != nil {
+			return nil, err
+		}
+	}
+	return o.upper.CreateTemp(dir, pattern)
+}
+
+// MkdirTemp implements TempFS
+func (o *overlay) MkdirTemp(dir, pattern string) (string, error) {
+	if dir != "" {
+		if err := o.copyUp(dir); err != nil {
+			return "", err
+		}
+	}
+	return o.upper.MkdirTemp(dir, pattern)
+}
+
+// Watch implements WatchFS. All changes are written to the upper layer so the watched path is copied up and watched there
+func (o *overlay) Watch(name string, recursive bool) (Watcher, error) {
+	if err := o.copyUp(name); err != nil {
+		return nil, err
+	}
+	return o.upper.Watch(name, recursive)
+}
+
+// copyUp copies the named entry from the base to the upper layer along with any missing parent directories
+func (o *overlay) copyUp(name string) error {
+	if o.inUpper(name) {
+		return nil
+	}
+	if !o.inBase(name) {
+		return o.copyUpParent(name)
+	}
+	if err := o.copyUpParent(name); err != nil {
+		return err
+	}
+
+	info, err := o.base.Lstat(name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&iofs.ModeSymlink != 0:
+		target, err := o.base.Readlink(name)
+		if err != nil {
+			return err
+		}
+		return o.upper.Symlink(target, name)
+	case info.IsDir():
+		err = o.upper.Mkdir(name, info.Mode().Perm())
+	default:
+		var data []byte
+		data, err = o.base.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		err = o.upper.WriteFile(name, data, info.Mode().Perm())
+	}
+	if err != nil {
+		return err
+	}
+	return o.upper.Chtimes(name, info.ModTime(), info.ModTime())
+}
+
+// copyUpParent copies the parent directory of name to the upper layer
+func (o *overlay) copyUpParent(name string) error {
+	parent := o.path.Dir(name)
+	if parent == name || o.path.Clean(parent) == o.path.Clean(name) {
+		return nil
+	}
+	return o.copyUp(parent)
+}
+
+func (o *overlay) inUpper(name string) bool {
+	_, err := o.upper.Lstat(name)
+	return err == nil
+}
+
+func (o *overlay) inBase(name string) bool {
+	if o.hidden(name) {
+		return false
+	}
+	_, err := o.base.Lstat(name)
+	return err == nil
+}
+
+func (o *overlay) lexists(name string) (bool, error) {
+	_, err := o.Lstat(name)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, iofs.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// hidden returns true if the base entry for name is hidden by a whiteout of the name or an ancestor, or by an opaque ancestor
+func (o *overlay) hidden(name string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	key := o.key(name)
+	for current := key; ; {
+		if _, ok := o.whiteouts[current]; ok {
+			return true
+		}
+		if _, ok := o.opaque[current]; ok && current != key {
+			return true
+		}
+		parent := o.path.Dir(current)
+		if parent == current {
+			return false
+		}
+		current = parent
+	}
+}
+
+func (o *overlay) isOpaque(name string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	_, ok := o.opaque[o.key(name)]
+	return ok
+}
+
+// whiteout hides the base entry for name
+func (o *overlay) whiteout(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.whiteouts[o.key(name)] = struct{}{}
+}
+
+// unhide removes the whiteout for name after it is created in the upper layer. Directories created in the upper
+// layer are always opaque so base children hidden by a whiteout of the name or an ancestor never reappear
+func (o *overlay) unhide(name string, dir bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	key := o.key(name)
+	delete(o.whiteouts, key)
+	if dir {
+		o.opaque[key] = struct{}{}
+	}
+}
+
+func (o *overlay) key(name string) string {
+	key, err := o.path.Normalize(o.path.Clean(name))
+	if err != nil {
+		return name
+	}
+	return key
+}
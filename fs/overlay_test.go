@@ -0,0 +1,264 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	gos "os"
+	"syscall"
+	"testing"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func newOverlay(t *testing.T) (fs.FS, fs.FS, fs.FS) {
+	base, path := newMemory(newOS(platform.Linux))
+	upper, _ := newMemory(newOS(platform.Linux))
+
+	require.NoError(t, base.MkdirAll("/fixture/sub", 0755))
+	require.NoError(t, base.WriteFile("/fixture/one.txt", []byte("one"), 0644))
+	require.NoError(t, base.WriteFile("/fixture/two.txt", []byte("two"), 0644))
+	require.NoError(t, base.WriteFile("/fixture/sub/three.txt", []byte("three"), 0644))
+
+	return fs.NewOverlay(base, upper, path), base, upper
+}
+
+func names(t *testing.T, fsys fs.FS, dir string) []string {
+	entries, err := fsys.ReadDir(dir)
+	require.NoError(t, err)
+	var result []string
+	for _, entry := range entries {
+		result = append(result, entry.Name())
+	}
+	return result
+}
+
+func TestOverlayReadsFallThrough(t *testing.T) {
+	overlay, _, _ := newOverlay(t)
+
+	content, err := overlay.ReadFile("/fixture/sub/three.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("three"), content)
+
+	require.Equal(t, []string{"one.txt", "sub", "two.txt"}, names(t, overlay, "/fixture"))
+}
+
+func TestOverlayWritesGoToUpper(t *testing.T) {
+	overlay, base, upper := newOverlay(t)
+
+	require.NoError(t, overlay.WriteFile("/fixture/one.txt", []byte("changed"), 0644))
+	require.NoError(t, overlay.WriteFile("/fixture/sub/four.txt", []byte("four"), 0644))
+
+	content, err := overlay.ReadFile("/fixture/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("changed"), content)
+
+	content, err = base.ReadFile("/fixture/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("one"), content)
+
+	ok, err := base.Exists("/fixture/sub/four.txt")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = upper.Exists("/fixture/sub/four.txt")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.Equal(t, []string{"four.txt", "three.txt"}, names(t, overlay, "/fixture/sub"))
+}
+
+func TestOverlayCopiesUpBeforeAppend(t *testing.T) {
+	overlay, base, _ := newOverlay(t)
+
+	f, err := overlay.OpenFile("/fixture/two.txt", gos.O_WRONLY|gos.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(" more"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := overlay.ReadFile("/fixture/two.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("two more"), content)
+
+	content, err = base.ReadFile("/fixture/two.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("two"), content)
+}
+
+func TestOverlayRemoveRecordsWhiteout(t *testing.T) {
+	overlay, base, _ := newOverlay(t)
+
+	require.NoError(t, overlay.Remove("/fixture/one.txt"))
+
+	_, err := overlay.Stat("/fixture/one.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	require.Equal(t, []string{"sub", "two.txt"}, names(t, overlay, "/fixture"))
+
+	ok, err := base.Exists("/fixture/one.txt")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// removing a non empty directory fails
+	err = overlay.Remove("/fixture/sub")
+	require.Error(t, err)
+
+	// recreating the file removes the whiteout
+	require.NoError(t, overlay.WriteFile("/fixture/one.txt", []byte("new"), 0644))
+	content, err := overlay.ReadFile("/fixture/one.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), content)
+}
+
+func TestOverlayRecreatedDirectoryIsOpaque(t *testing.T) {
+	overlay, _, _ := newOverlay(t)
+
+	require.NoError(t, overlay.RemoveAll("/fixture/sub"))
+	ok, err := overlay.Exists("/fixture/sub/three.txt")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, overlay.Mkdir("/fixture/sub", 0755))
+	entries, err := overlay.ReadDir("/fixture/sub")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	ok, err = overlay.Exists("/fixture/sub/three.txt")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestOverlayRename(t *testing.T) {
+	overlay, base, _ := newOverlay(t)
+
+	require.NoError(t, overlay.Rename("/fixture/one.txt", "/fixture/renamed.txt"))
+	require.Equal(t, []string{"renamed.txt", "sub", "two.txt"}, names(t, overlay, "/fixture"))
+
+	content, err := overlay.ReadFile("/fixture/renamed.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("one"), content)
+
+	ok, err := base.Exists("/fixture/one.txt")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestOverlayRenameOverExisting(t *testing.T) {
+	type test struct {
+		name     string
+		setup    func(t *testing.T, overlay fs.FS)
+		old, new string
+		err      error
+	}
+	tests := []test{
+		{"base_directory_onto_directory", func(t *testing.T, overlay fs.FS) {
+			require.NoError(t, overlay.MkdirAll("/keep", 0755))
+			require.NoError(t, overlay.WriteFile("/keep/important.txt", []byte("important"), 0644))
+		}, "/fixture/sub", "/keep", syscall.EEXIST},
+		{"base_directory_onto_file", func(t *testing.T, overlay fs.FS) {
+			require.NoError(t, overlay.WriteFile("/keep", []byte("important"), 0644))
+		}, "/fixture/sub", "/keep", syscall.ENOTDIR},
+		{"upper_directory_onto_base_directory", func(t *testing.T, overlay fs.FS) {
+			require.NoError(t, overlay.MkdirAll("/upper", 0755))
+			require.NoError(t, overlay.WriteFile("/upper/new.txt", []byte("new"), 0644))
+		}, "/upper", "/fixture", syscall.EEXIST},
+		{"file_onto_directory", nil, "/fixture/one.txt", "/fixture/sub", syscall.EEXIST},
+		{"directory_into_itself", nil, "/fixture", "/fixture/sub/inner", syscall.EINVAL},
+		{"missing_parent", nil, "/fixture/sub", "/missing/sub", iofs.ErrNotExist},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			overlay, _, _ := newOverlay(t)
+			if test.setup != nil {
+				test.setup(t, overlay)
+			}
+			before := map[string][]byte{}
+			for _, name := range []string{"/keep/important.txt", "/keep", "/fixture/one.txt", "/fixture/sub/three.txt"} {
+				if content, err := overlay.ReadFile(name); err == nil {
+					before[name] = content
+				}
+			}
+
+			err := overlay.Rename(test.old, test.new)
+			require.ErrorIs(t, err, test.err)
+			var lerr *gos.LinkError
+			require.ErrorAs(t, err, &lerr)
+
+			// nothing is removed or merged when the rename fails
+			for name, content := range before {
+				actual, err := overlay.ReadFile(name)
+				require.NoError(t, err, name)
+				require.Equal(t, content, actual, name)
+			}
+			require.Equal(t, []string{"one.txt", "sub", "two.txt"}, names(t, overlay, "/fixture"))
+		})
+	}
+}
+
+func TestOverlayRenamedDirectoryIsOpaque(t *testing.T) {
+	overlay, _, _ := newOverlay(t)
+
+	require.NoError(t, overlay.RemoveAll("/fixture/sub"))
+	require.NoError(t, overlay.MkdirAll("/upper", 0755))
+	require.NoError(t, overlay.WriteFile("/upper/new.txt", []byte("new"), 0644))
+
+	// the base children of the removed directory stay hidden
+	require.NoError(t, overlay.Rename("/upper", "/fixture/sub"))
+	require.Equal(t, []string{"new.txt"}, names(t, overlay, "/fixture/sub"))
+
+	// moving a base directory leaves nothing visible at the old name
+	require.NoError(t, overlay.Rename("/fixture/sub", "/moved"))
+	require.NoError(t, overlay.Mkdir("/fixture/sub", 0755))
+	require.Empty(t, names(t, overlay, "/fixture/sub"))
+}
+
+func TestOverlayDirectoryTree(t *testing.T) {
+	overlay, _, _ := newOverlay(t)
+	path := filepath.NewProviderFromOS(newOS(platform.Linux))
+	c := NewConformanceWithProvider(overlay, path)
+	t.Run("rename_directory", func(t *testing.T) { c.TestRenameDirectory(t, "/fixture/sub") })
+	t.Run("rename_over_existing", func(t *testing.T) { c.TestRenameOverExisting(t, "/fixture") })
+}
+
+func TestOverlayWithOSBase(t *testing.T) {
+	path := filepath.NewProviderFromOS(os.New())
+	base := fs.New()
+	dir := t.TempDir()
+
+	fixture := path.Join(dir, "fixture.txt")
+	require.NoError(t, base.WriteFile(fixture, []byte("fixture"), 0644))
+
+	upper := fs.NewMemory(path)
+	overlay := fs.NewOverlay(base, upper, path)
+
+	require.NoError(t, overlay.WriteFile(fixture, []byte("changed"), 0644))
+	require.NoError(t, overlay.WriteFile(path.Join(dir, "new.txt"), []byte("new"), 0644))
+
+	content, err := overlay.ReadFile(fixture)
+	require.NoError(t, err)
+	require.Equal(t, []byte("changed"), content)
+
+	// the real directory is never modified
+	content, err = base.ReadFile(fixture)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fixture"), content)
+
+	entries, err := base.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.Equal(t, []string{"fixture.txt", "new.txt"}, names(t, overlay, dir))
+}
+
+func TestOverlayGlob(t *testing.T) {
+	overlay, _, _ := newOverlay(t)
+
+	require.NoError(t, overlay.WriteFile("/fixture/four.txt", []byte("four"), 0644))
+	require.NoError(t, overlay.Remove("/fixture/one.txt"))
+
+	matches, err := overlay.Glob("/fixture/*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/fixture/four.txt", "/fixture/two.txt"}, matches)
+}
@@ -0,0 +1,196 @@
+package fs
+
+import (
+	iofs "io/fs"
+	"os"
+	"time"
+)
+
+type readOnly struct {
+	fs FS
+}
+
+// NewReadOnly wraps the file system so every method that would modify it fails with ErrPermission.
+// Files returned from the wrapper also fail on Write and WriteAt.
+func NewReadOnly(fsys FS) FS {
+	return &readOnly{
+		fs: fsys,
+	}
+}
+
+func errReadOnly(op string, name string) error {
+	return &iofs.PathError{Op: op, Path: name, Err: iofs.ErrPermission}
+}
+
+func linkErrReadOnly(op string, oldname, newname string) error {
+	return &os.LinkError{Op: op, Old: oldname, New: newname, Err: iofs.ErrPermission}
+}
+
+// Open implements FS
+func (r *readOnly) Open(name string) (iofs.File, error) {
+	f, err := r.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := f.(File); ok {
+		return &readOnlyFile{File: file}, nil
+	}
+	return f, nil
+}
+
+// OpenFile implements FS
+func (r *readOnly) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, errReadOnly("open", name)
+	}
+	f, err := r.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{File: f}, nil
+}
+
+// Create implements FS
+func (r *readOnly) Create(name string) (File, error) {
+	return nil, errReadOnly("open", name)
+}
+
+// Rename implements FS
+func (r *readOnly) Rename(oldName, newName string) error {
+	return linkErrReadOnly("rename", oldName, newName)
+}
+
+// Remove implements FS
+func (r *readOnly) Remove(name string) error {
+	return errReadOnly("remove", name)
+}
+
+// RemoveAll implements FS
+func (r *readOnly) RemoveAll(name string) error {
+	return errReadOnly("removeall", name)
+}
+
+// Glob implements FS
+func (r *readOnly) Glob(pattern string) ([]string, error) {
+	return r.fs.Glob(pattern)
+}
+
+// ReadDir implements FS
+func (r *readOnly) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return r.fs.ReadDir(name)
+}
+
+// ReadFile implements FS
+func (r *readOnly) ReadFile(name string) ([]byte, error) {
+	return r.fs.ReadFile(name)
+}
+
+// WriteFile implements FS
+func (r *readOnly) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	return errReadOnly("open", name)
+}
+
+// Exists implements FS
+func (r *readOnly) Exists(name string) (bool, error) {
+	return r.fs.Exists(name)
+}
+
+// Stat implements FS
+func (r *readOnly) Stat(name string) (iofs.FileInfo, error) {
+	return r.fs.Stat(name)
+}
+
+// Sub implements FS. Sub file systems are also read only
+func (r *readOnly) Sub(dir string) (iofs.FS, error) {
+	sub, err := r.fs.Sub(dir)
+	if err != nil {
+		return nil, err
+	}
+	if full, ok := sub.(FS); ok {
+		return NewReadOnly(full), nil
+	}
+	return sub, nil
+}
+
+// Mkdir implements MakeDirFS
+func (r *readOnly) Mkdir(name string, perm iofs.FileMode) error {
+	return errReadOnly("mkdir", name)
+}
+
+// MkdirAll implements MakeDirFS
+func (r *readOnly) MkdirAll(name string, perm iofs.FileMode) error {
+	return errReadOnly("mkdir", name)
+}
+
+// Chmod implements ChmodFS
+func (r *readOnly) Chmod(name string, mode iofs.FileMode) error {
+	return errReadOnly("chmod", name)
+}
+
+// Symlink implements SymlinkFS
+func (r *readOnly) Symlink(oldname, newname string) error {
+	return linkErrReadOnly("symlink", oldname, newname)
+}
+
+// Readlink implements SymlinkFS
+func (r *readOnly) Readlink(name string) (string, error) {
+	return r.fs.Readlink(name)
+}
+
+// Lstat implements SymlinkFS
+func (r *readOnly) Lstat(name string) (iofs.FileInfo, error) {
+	return r.fs.Lstat(name)
+}
+
+// Link implements LinkFS
+func (r *readOnly) Link(oldname, newname string) error {
+	return linkErrReadOnly("link", oldname, newname)
+}
+
+// Chtimes implements ChtimesFS
+func (r *readOnly) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return errReadOnly("chtimes", name)
+}
+
+// Chown implements ChownFS
+func (r *readOnly) Chown(name string, uid, gid int) error {
+	return errReadOnly("chown", name)
+}
+
+// Lchown implements ChownFS
+func (r *readOnly) Lchown(name string, uid, gid int) error {
+	return errReadOnly("lchown", name)
+}
+
+// CreateTemp implements TempFS
+func (r *readOnly) CreateTemp(dir, pattern string) (File, error) {
+	return nil, errReadOnly("createtemp", dir)
+}
+
+// MkdirTemp implements TempFS
+func (r *readOnly) MkdirTemp(dir, pattern string) (string, error) {
+	return "", errReadOnly("mkdirtemp", dir)
+}
+
+// Watch implements WatchFS
+func (r *readOnly) Watch(name string, recursive bool) (Watcher, error) {
+	return r.fs.Watch(name, recursive)
+}
+
+// readOnlyFile wraps a file so writes through the handle fail
+type readOnlyFile struct {
+	File
+}
+
+func (f *readOnlyFile) Write(b []byte) (int, error) {
+	return 0, errReadOnly("write", f.Name())
+}
+
+func (f *readOnlyFile) WriteAt(b []byte, offset int64) (int, error) {
+	return 0, errReadOnly("write", f.Name())
+}
+
+// ReadDir reads the entries of a directory. Listing a directory doesn't modify it so it is allowed
+func (f *readOnlyFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	return readDir(f.File, f.Name(), n)
+}
@@ -0,0 +1,93 @@
+package fs_test
+
+import (
+	"io"
+	iofs "io/fs"
+	gos "os"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func newReadOnly(t *testing.T) fs.FS {
+	fsys, _ := newMemory(newOS(platform.Linux))
+	require.NoError(t, fsys.MkdirAll("/opt/app", 0755))
+	require.NoError(t, fsys.WriteFile("/opt/app/config.yaml", []byte("config"), 0644))
+	return fs.NewReadOnly(fsys)
+}
+
+func TestReadOnlyRejectsMutations(t *testing.T) {
+	type test struct {
+		name   string
+		mutate func(fs.FS) error
+	}
+	tests := []test{
+		{"create", func(fsys fs.FS) error { _, err := fsys.Create("/opt/app/new.txt"); return err }},
+		{"open_file_write", func(fsys fs.FS) error {
+			_, err := fsys.OpenFile("/opt/app/config.yaml", gos.O_WRONLY, 0644)
+			return err
+		}},
+		{"open_file_create", func(fsys fs.FS) error {
+			_, err := fsys.OpenFile("/opt/app/new.txt", gos.O_RDONLY|gos.O_CREATE, 0644)
+			return err
+		}},
+		{"write_file", func(fsys fs.FS) error { return fsys.WriteFile("/opt/app/config.yaml", nil, 0644) }},
+		{"rename", func(fsys fs.FS) error { return fsys.Rename("/opt/app/config.yaml", "/opt/app/other.yaml") }},
+		{"remove", func(fsys fs.FS) error { return fsys.Remove("/opt/app/config.yaml") }},
+		{"remove_all", func(fsys fs.FS) error { return fsys.RemoveAll("/opt/app") }},
+		{"mkdir", func(fsys fs.FS) error { return fsys.Mkdir("/opt/app/dir", 0755) }},
+		{"mkdir_all", func(fsys fs.FS) error { return fsys.MkdirAll("/opt/app/dir/sub", 0755) }},
+		{"chmod", func(fsys fs.FS) error { return fsys.Chmod("/opt/app/config.yaml", 0600) }},
+		{"symlink", func(fsys fs.FS) error { return fsys.Symlink("config.yaml", "/opt/app/link.yaml") }},
+		{"link", func(fsys fs.FS) error { return fsys.Link("/opt/app/config.yaml", "/opt/app/link.yaml") }},
+		{"chtimes", func(fsys fs.FS) error { return fsys.Chtimes("/opt/app/config.yaml", time.Now(), time.Now()) }},
+		{"chown", func(fsys fs.FS) error { return fsys.Chown("/opt/app/config.yaml", 0, 0) }},
+		{"lchown", func(fsys fs.FS) error { return fsys.Lchown("/opt/app/config.yaml", 0, 0) }},
+		{"create_temp", func(fsys fs.FS) error { _, err := fsys.CreateTemp("/opt/app", "tmp"); return err }},
+		{"mkdir_temp", func(fsys fs.FS) error { _, err := fsys.MkdirTemp("/opt/app", "tmp"); return err }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := newReadOnly(t)
+			require.ErrorIs(t, test.mutate(fsys), iofs.ErrPermission)
+
+			content, err := fsys.ReadFile("/opt/app/config.yaml")
+			require.NoError(t, err)
+			require.Equal(t, []byte("config"), content)
+
+			entries, err := fsys.ReadDir("/opt/app")
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestReadOnlyFilesRejectWrites(t *testing.T) {
+	fsys := newReadOnly(t)
+
+	f, err := fsys.OpenFile("/opt/app/config.yaml", gos.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("changed"))
+	require.ErrorIs(t, err, iofs.ErrPermission)
+
+	_, err = f.WriteAt([]byte("changed"), 0)
+	require.ErrorIs(t, err, iofs.ErrPermission)
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, []byte("config"), content)
+
+	opened, err := fsys.Open("/opt/app/config.yaml")
+	require.NoError(t, err)
+	defer opened.Close()
+
+	writer, ok := opened.(io.Writer)
+	require.True(t, ok)
+	_, err = writer.Write([]byte("changed"))
+	require.ErrorIs(t, err, iofs.ErrPermission)
+}
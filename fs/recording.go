@@ -0,0 +1,400 @@
+package fs
+
+import (
+	"encoding/json"
+	iofs "io/fs"
+	"sync"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+// Record is a call made through a recording file system
+type Record struct {
+	// Op is the name of the method, for example "WriteFile". Methods of files returned from the file system are
+	// prefixed with "File.", for example "File.Write"
+	Op string `json:"op"`
+	// Path is the name passed to the method normalized with the path provider. File methods record the name the file
+	// was opened with
+	Path string `json:"path"`
+	// NewPath is the normalized second name of Rename, Symlink and Link
+	NewPath string `json:"newPath,omitempty"`
+	// Flag is the flag passed to OpenFile
+	Flag int `json:"flag,omitempty"`
+	// Mode is the permission or mode passed to the method
+	Mode iofs.FileMode `json:"mode,omitempty"`
+	// Bytes is the number of bytes read or written
+	Bytes int `json:"bytes,omitempty"`
+	// Err is the error returned from the call
+	Err error `json:"-"`
+	// Error is the message of Err and is empty if the call succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// RecordingFS is a file system that records every call made through it
+type RecordingFS interface {
+	FS
+	json.Marshaler
+	// Records returns a copy of the calls recorded so far in the order they were made
+	Records() []Record
+	// Reset removes the recorded calls
+	Reset()
+}
+
+type recording struct {
+	fs   FS
+	path filepath.Provider
+	// dir is the directory relative names are recorded in for file systems returned from Sub
+	dir string
+	log *recordLog
+}
+
+// recordLog holds the records of a recording file system and the file systems returned from its Sub method
+type recordLog struct {
+	// mu guards the records
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewRecording wraps the file system so every call is recorded. Paths are normalized with the path provider so
+// records from case insensitive file systems compare equal regardless of the case used by the caller. The records
+// can be exported as JSON with json.Marshal
+func NewRecording(fsys FS, path filepath.Provider) RecordingFS {
+	return &recording{
+		fs:   fsys,
+		path: path,
+		log:  &recordLog{},
+	}
+}
+
+// Records implements RecordingFS
+func (r *recording) Records() []Record {
+	r.log.mu.Lock()
+	defer r.log.mu.Unlock()
+	return append([]Record{}, r.log.records...)
+}
+
+// Reset implements RecordingFS
+func (r *recording) Reset() {
+	r.log.mu.Lock()
+	defer r.log.mu.Unlock()
+	r.log.records = nil
+}
+
+// MarshalJSON encodes the records as a JSON array
+func (r *recording) MarshalJSON() ([]byte, error) {
+	records := r.Records()
+	if records == nil {
+		records = []Record{}
+	}
+	return json.Marshal(records)
+}
+
+// normalize returns the normalized full name or the name unchanged if it can't be parsed
+func (r *recording) normalize(name string) string {
+	if r.dir != "" {
+		if fp, err := r.path.Parse(name); err == nil && !fp.IsAbs() {
+			name = r.path.Join(r.dir, name)
+		}
+	}
+	normalized, err := r.path.Normalize(name)
+	if err != nil {
+		return name
+	}
+	return normalized
+}
+
+func (r *recording) record(record Record, err error) {
+	record.Err = err
+	if err != nil {
+		record.Error = err.Error()
+	}
+	r.log.mu.Lock()
+	defer r.log.mu.Unlock()
+	r.log.records = append(r.log.records, record)
+}
+
+func (r *recording) call(op string, name string, err error) {
+	r.record(Record{Op: op, Path: r.normalize(name)}, err)
+}
+
+func (r *recording) wrap(file File, name string) File {
+	return &recordingFile{File: file, fs: r, name: r.normalize(name)}
+}
+
+// Open implements FS
+func (r *recording) Open(name string) (iofs.File, error) {
+	f, err := r.fs.Open(name)
+	r.call("Open", name, err)
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := f.(File); ok {
+		return r.wrap(file, name), nil
+	}
+	return f, nil
+}
+
+// OpenFile implements FS
+func (r *recording) OpenFile(name string, flag int, perm iofs.FileMode) (File, error) {
+	f, err := r.fs.OpenFile(name, flag, perm)
+	r.record(Record{Op: "OpenFile", Path: r.normalize(name), Flag: flag, Mode: perm}, err)
+	if err != nil {
+		return nil, err
+	}
+	return r.wrap(f, name), nil
+}
+
+// Create implements FS
+func (r *recording) Create(name string) (File, error) {
+	f, err := r.fs.Create(name)
+	r.call("Create", name, err)
+	if err != nil {
+		return nil, err
+	}
+	return r.wrap(f, name), nil
+}
+
+// Rename implements FS
+func (r *recording) Rename(oldName, newName string) error {
+	err := r.fs.Rename(oldName, newName)
+	r.record(Record{Op: "Rename", Path: r.normalize(oldName), NewPath: r.normalize(newName)}, err)
+	return err
+}
+
+// Remove implements FS
+func (r *recording) Remove(name string) error {
+	err := r.fs.Remove(name)
+	r.call("Remove", name, err)
+	return err
+}
+
+// RemoveAll implements FS
+func (r *recording) RemoveAll(name string) error {
+	err := r.fs.RemoveAll(name)
+	r.call("RemoveAll", name, err)
+	return err
+}
+
+// Glob implements FS. The pattern is recorded as the path
+func (r *recording) Glob(pattern string) ([]string, error) {
+	matches, err := r.fs.Glob(pattern)
+	r.call("Glob", pattern, err)
+	return matches, err
+}
+
+// ReadDir implements FS
+func (r *recording) ReadDir(name string) ([]iofs.DirEntry, error) {
+	entries, err := r.fs.ReadDir(name)
+	r.call("ReadDir", name, err)
+	return entries, err
+}
+
+// ReadFile implements FS
+func (r *recording) ReadFile(name string) ([]byte, error) {
+	data, err := r.fs.ReadFile(name)
+	r.record(Record{Op: "ReadFile", Path: r.normalize(name), Bytes: len(data)}, err)
+	return data, err
+}
+
+// WriteFile implements FS
+func (r *recording) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	err := r.fs.WriteFile(name, data, perm)
+	r.record(Record{Op: "WriteFile", Path: r.normalize(name), Mode: perm, Bytes: len(data)}, err)
+	return err
+}
+
+// Exists implements FS
+func (r *recording) Exists(name string) (bool, error) {
+	ok, err := r.fs.Exists(name)
+	r.call("Exists", name, err)
+	return ok, err
+}
+
+// Stat implements FS
+func (r *recording) Stat(name string) (iofs.FileInfo, error) {
+	info, err := r.fs.Stat(name)
+	r.call("Stat", name, err)
+	return info, err
+}
+
+// Sub implements FS. Calls through the sub file system are recorded with their full path
+func (r *recording) Sub(dir string) (iofs.FS, error) {
+	// the sub file system wraps the sub directory of the wrapped file system so the calls it makes to check names
+	// are not recorded
+	s, err := r.fs.Sub(dir)
+	r.call("Sub", dir, err)
+	if err != nil {
+		return nil, err
+	}
+	sub, ok := s.(FS)
+	if !ok {
+		return s, nil
+	}
+	return &recording{
+		fs:   sub,
+		path: r.path,
+		dir:  r.normalize(dir),
+		log:  r.log,
+	}, nil
+}
+
+// Mkdir implements MakeDirFS
+func (r *recording) Mkdir(name string, perm iofs.FileMode) error {
+	err := r.fs.Mkdir(name, perm)
+	r.record(Record{Op: "Mkdir", Path: r.normalize(name), Mode: perm}, err)
+	return err
+}
+
+// MkdirAll implements MakeDirFS
+func (r *recording) MkdirAll(name string, perm iofs.FileMode) error {
+	err := r.fs.MkdirAll(name, perm)
+	r.record(Record{Op: "MkdirAll", Path: r.normalize(name), Mode: perm}, err)
+	return err
+}
+
+// Chmod implements ChmodFS
+func (r *recording) Chmod(name string, mode iofs.FileMode) error {
+	err := r.fs.Chmod(name, mode)
+	r.record(Record{Op: "Chmod", Path: r.normalize(name), Mode: mode}, err)
+	return err
+}
+
+// Symlink implements SymlinkFS. The link target is recorded unchanged as the path
+func (r *recording) Symlink(oldname, newname string) error {
+	err := r.fs.Symlink(oldname, newname)
+	r.record(Record{Op: "Symlink", Path: oldname, NewPath: r.normalize(newname)}, err)
+	return err
+}
+
+// Readlink implements SymlinkFS
+func (r *recording) Readlink(name string) (string, error) {
+	target, err := r.fs.Readlink(name)
+	r.call("Readlink", name, err)
+	return target, err
+}
+
+// Lstat implements SymlinkFS
+func (r *recording) Lstat(name string) (iofs.FileInfo, error) {
+	info, err := r.fs.Lstat(name)
+	r.call("Lstat", name, err)
+	return info, err
+}
+
+// Link implements LinkFS
+func (r *recording) Link(oldname, newname string) error {
+	err := r.fs.Link(oldname, newname)
+	r.record(Record{Op: "Link", Path: r.normalize(oldname), NewPath: r.normalize(newname)}, err)
+	return err
+}
+
+// Chtimes implements ChtimesFS
+func (r *recording) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	err := r.fs.Chtimes(name, atime, mtime)
+	r.call("Chtimes", name, err)
+	return err
+}
+
+// Chown implements ChownFS
+func (r *recording) Chown(name string, uid, gid int) error {
+	err := r.fs.Chown(name, uid, gid)
+	r.call("Chown", name, err)
+	return err
+}
+
+// Lchown implements ChownFS
+func (r *recording) Lchown(name string, uid, gid int) error {
+	err := r.fs.Lchown(name, uid, gid)
+	r.call("Lchown", name, err)
+	return err
+}
+
+// CreateTemp implements TempFS. The name of the created file is recorded as the path
+func (r *recording) CreateTemp(dir, pattern string) (File, error) {
+	f, err := r.fs.CreateTemp(dir, pattern)
+	if err != nil {
+		r.call("CreateTemp", dir, err)
+		return nil, err
+	}
+	r.call("CreateTemp", f.Name(), nil)
+	return r.wrap(f, f.Name()), nil
+}
+
+// MkdirTemp implements TempFS. The name of the created directory is recorded as the path
+func (r *recording) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := r.fs.MkdirTemp(dir, pattern)
+	if err != nil {
+		r.call("MkdirTemp", dir, err)
+		return "", err
+	}
+	r.call("MkdirTemp", name, nil)
+	return name, nil
+}
+
+// Watch implements WatchFS
+func (r *recording) Watch(name string, recursive bool) (Watcher, error) {
+	w, err := r.fs.Watch(name, recursive)
+	r.call("Watch", name, err)
+	return w, err
+}
+
+// recordingFile wraps a file so calls through the handle are recorded
+type recordingFile struct {
+	File
+	fs *recording
+	// name is the normalized name the file was opened with
+	name string
+}
+
+func (f *recordingFile) bytes(op string, n int, err error) {
+	f.fs.record(Record{Op: op, Path: f.name, Bytes: n}, err)
+}
+
+func (f *recordingFile) Stat() (iofs.FileInfo, error) {
+	info, err := f.File.Stat()
+	f.fs.record(Record{Op: "File.Stat", Path: f.name}, err)
+	return info, err
+}
+
+func (f *recordingFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.bytes("File.Read", n, err)
+	return n, err
+}
+
+func (f *recordingFile) ReadAt(b []byte, offset int64) (int, error) {
+	n, err := f.File.ReadAt(b, offset)
+	f.bytes("File.ReadAt", n, err)
+	return n, err
+}
+
+func (f *recordingFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	f.bytes("File.Write", n, err)
+	return n, err
+}
+
+func (f *recordingFile) WriteAt(b []byte, offset int64) (int, error) {
+	n, err := f.File.WriteAt(b, offset)
+	f.bytes("File.WriteAt", n, err)
+	return n, err
+}
+
+func (f *recordingFile) Seek(offset int64, whence int) (int64, error) {
+	position, err := f.File.Seek(offset, whence)
+	f.fs.record(Record{Op: "File.Seek", Path: f.name}, err)
+	return position, err
+}
+
+func (f *recordingFile) Close() error {
+	err := f.File.Close()
+	f.fs.record(Record{Op: "File.Close", Path: f.name}, err)
+	return err
+}
+
+// ReadDir reads the entries of a directory and records the call as "File.ReadDir"
+func (f *recordingFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	entries, err := readDir(f.File, f.Name(), n)
+	f.fs.record(Record{Op: "File.ReadDir", Path: f.name}, err)
+	return entries, err
+}
@@ -0,0 +1,112 @@
+package fs_test
+
+import (
+	"encoding/json"
+	iofs "io/fs"
+	gos "os"
+	"testing"
+
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecording(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Linux))
+	require.NoError(t, fsys.MkdirAll("/home/fake", 0755))
+	recording := fs.NewRecording(fsys, path)
+
+	require.NoError(t, recording.WriteFile("/home/fake/config.yaml", []byte("config"), 0644))
+	f, err := recording.OpenFile("/home/fake/log.txt", gos.O_CREATE|gos.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("line"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, recording.Rename("/home/fake/log.txt", "/home/fake/old.txt"))
+	_, err = recording.ReadFile("/home/fake/missing.txt")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	records := recording.Records()
+	require.Len(t, records, 6)
+	require.Equal(t, fs.Record{Op: "WriteFile", Path: "/home/fake/config.yaml", Mode: 0644, Bytes: 6}, records[0])
+	require.Equal(t, fs.Record{Op: "OpenFile", Path: "/home/fake/log.txt", Flag: gos.O_CREATE | gos.O_WRONLY, Mode: 0600}, records[1])
+	require.Equal(t, fs.Record{Op: "File.Write", Path: "/home/fake/log.txt", Bytes: 4}, records[2])
+	require.Equal(t, fs.Record{Op: "File.Close", Path: "/home/fake/log.txt"}, records[3])
+	require.Equal(t, fs.Record{Op: "Rename", Path: "/home/fake/log.txt", NewPath: "/home/fake/old.txt"}, records[4])
+	require.Equal(t, "ReadFile", records[5].Op)
+	require.ErrorIs(t, records[5].Err, iofs.ErrNotExist)
+	require.NotEmpty(t, records[5].Error)
+
+	recording.Reset()
+	require.Empty(t, recording.Records())
+}
+
+func TestRecordingNormalizesPaths(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Windows))
+	require.NoError(t, fsys.MkdirAll(`c:\Users\Fake`, 0755))
+	recording := fs.NewRecording(fsys, path)
+
+	require.NoError(t, recording.WriteFile(`C:\Users\Fake\Config.yaml`, []byte("one"), 0644))
+	require.NoError(t, recording.WriteFile(`c:/users/fake/config.yaml`, []byte("two"), 0644))
+
+	// both writes are recorded with the same name so the config was written to a single file twice
+	var writes []string
+	for _, record := range recording.Records() {
+		if record.Op == "WriteFile" {
+			writes = append(writes, record.Path)
+		}
+	}
+	require.Equal(t, []string{`c:\users\fake\config.yaml`, `c:\users\fake\config.yaml`}, writes)
+}
+
+func TestRecordingJSON(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Linux))
+	recording := fs.NewRecording(fsys, path)
+
+	data, err := json.Marshal(recording)
+	require.NoError(t, err)
+	require.JSONEq(t, `[]`, string(data))
+
+	require.NoError(t, recording.MkdirAll("/opt/app", 0755))
+	_, err = recording.Stat("/opt/missing")
+	require.Error(t, err)
+
+	data, err = json.Marshal(recording)
+	require.NoError(t, err)
+
+	var records []fs.Record
+	require.NoError(t, json.Unmarshal(data, &records))
+	require.Len(t, records, 2)
+	require.Equal(t, "MkdirAll", records[0].Op)
+	require.Equal(t, "/opt/app", records[0].Path)
+	require.Equal(t, iofs.FileMode(0755), records[0].Mode)
+	require.Empty(t, records[0].Error)
+	require.Equal(t, "Stat", records[1].Op)
+	require.NotEmpty(t, records[1].Error)
+}
+
+func TestRecordingSub(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Linux))
+	require.NoError(t, fsys.MkdirAll("/opt/app", 0755))
+	recording := fs.NewRecording(fsys, path)
+
+	sub, err := recording.Sub("/opt/app")
+	require.NoError(t, err)
+	full, ok := sub.(fs.FS)
+	require.True(t, ok)
+	require.NoError(t, full.WriteFile("file.txt", []byte("data"), 0644))
+
+	var calls []string
+	for _, record := range recording.Records() {
+		calls = append(calls, record.Op+" "+record.Path)
+	}
+	require.Equal(t, []string{"Sub /opt/app", "WriteFile /opt/app/file.txt"}, calls)
+
+	// calls through the sub file system are part of the records of the parent
+	require.Len(t, sub.(fs.RecordingFS).Records(), 2)
+	recording.Reset()
+	nested, err := full.Sub("nested")
+	require.Error(t, err)
+	require.Nil(t, nested)
+	require.Equal(t, []fs.Record{{Op: "Sub", Path: "/opt/app/nested", Err: err, Error: err.Error()}}, recording.Records())
+}
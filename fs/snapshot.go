@@ -0,0 +1,229 @@
+package fs
+
+import (
+	"bytes"
+	iofs "io/fs"
+	"sort"
+	"strings"
+	"testing/fstest"
+)
+
+// SnapshotFS is a file system whose state can be captured and restored
+type SnapshotFS interface {
+	// Snapshot captures the path, mode, data, times and ownership of every file, directory and symbolic link
+	Snapshot() *Snapshot
+	// Restore replaces the state of the file system with the snapshot. Watchers are notified of the differences
+	Restore(snapshot *Snapshot) error
+}
+
+// Snapshot is the captured state of a file system. A snapshot is not changed by later changes to the file system
+// and can be restored any number of times
+type Snapshot struct {
+	entries map[string]snapshotEntry
+}
+
+type snapshotEntry struct {
+	// name is the full path of the entry with the case it was written in
+	name string
+	// base is the final element of the path
+	base string
+	file *fstest.MapFile
+}
+
+// Paths returns the sorted paths of every entry in the snapshot
+func (s *Snapshot) Paths() []string {
+	var paths []string
+	for _, entry := range s.entries {
+		paths = append(paths, entry.name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ChangeKind is the kind of difference between two snapshots
+type ChangeKind int
+
+const (
+	// ChangeAdded means the entry only exists in the second snapshot
+	ChangeAdded ChangeKind = iota
+	// ChangeRemoved means the entry only exists in the first snapshot
+	ChangeRemoved
+	// ChangeModified means the entry exists in both snapshots with different content or attributes
+	ChangeModified
+)
+
+// String returns the name of the kind
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change describes an entry that differs between two snapshots
+type Change struct {
+	// Kind is the kind of difference
+	Kind ChangeKind
+	// Path is the full path of the entry
+	Path string
+	// Fields lists what differs for modified entries, any of "type", "mode", "data", "modtime" or "owner"
+	Fields []string
+}
+
+// String describes the change, for example "added /home/fake/.config/app.yaml" or "modified /etc/hosts (data, modtime)"
+func (c Change) String() string {
+	s := c.Kind.String() + " " + c.Path
+	if len(c.Fields) > 0 {
+		s += " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	return s
+}
+
+// Diff returns the changes needed to turn before into after sorted by path. Modification times of directories change
+// whenever their children are added or removed so they are not reported, access times are never reported
+func Diff(before, after *Snapshot) []Change {
+	var changes []Change
+	for _, change := range diffKeys(before, after) {
+		changes = append(changes, change.Change)
+	}
+	return changes
+}
+
+type keyedChange struct {
+	Change
+	key string
+}
+
+func diffKeys(before, after *Snapshot) []keyedChange {
+	var changes []keyedChange
+	for key, entry := range after.entries {
+		previous, ok := before.entries[key]
+		if !ok {
+			changes = append(changes, keyedChange{Change: Change{Kind: ChangeAdded, Path: entry.name}, key: key})
+			continue
+		}
+		if fields := diffFile(previous.file, entry.file); len(fields) > 0 {
+			changes = append(changes, keyedChange{Change: Change{Kind: ChangeModified, Path: entry.name, Fields: fields}, key: key})
+		}
+	}
+	for key, entry := range before.entries {
+		if _, ok := after.entries[key]; !ok {
+			changes = append(changes, keyedChange{Change: Change{Kind: ChangeRemoved, Path: entry.name}, key: key})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func diffFile(before, after *fstest.MapFile) []string {
+	var fields []string
+	if before.Mode.Type() != after.Mode.Type() {
+		fields = append(fields, "type")
+	}
+	if before.Mode.Perm() != after.Mode.Perm() {
+		fields = append(fields, "mode")
+	}
+	if !bytes.Equal(before.Data, after.Data) {
+		fields = append(fields, "data")
+	}
+	if !before.ModTime.Equal(after.ModTime) && !(before.Mode.IsDir() && after.Mode.IsDir()) {
+		fields = append(fields, "modtime")
+	}
+	beforeStat, _ := before.Sys.(*MemoryStat)
+	afterStat, _ := after.Sys.(*MemoryStat)
+	if beforeStat != nil && afterStat != nil && (beforeStat.Uid != afterStat.Uid || beforeStat.Gid != afterStat.Gid) {
+		fields = append(fields, "owner")
+	}
+	return fields
+}
+
+// cloneFiles deep copies the files keeping hard links to the same file shared in the copy
+func cloneFiles(files map[string]*fstest.MapFile) map[string]*fstest.MapFile {
+	clones := map[*fstest.MapFile]*fstest.MapFile{}
+	result := make(map[string]*fstest.MapFile, len(files))
+	for key, file := range files {
+		clone, ok := clones[file]
+		if !ok {
+			copied := *file
+			copied.Data = append([]byte(nil), file.Data...)
+			if stat, ok := file.Sys.(*MemoryStat); ok {
+				sys := *stat
+				copied.Sys = &sys
+			}
+			clone = &copied
+			clones[file] = clone
+		}
+		result[key] = clone
+	}
+	return result
+}
+
+// Snapshot implements SnapshotFS
+func (m *memory) Snapshot() *Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshot()
+}
+
+// snapshot captures the state of the file system. The caller must hold the file system lock
+func (m *memory) snapshot() *Snapshot {
+	files := cloneFiles(m.fs)
+	entries := make(map[string]snapshotEntry, len(files))
+	for key, file := range files {
+		entries[key] = snapshotEntry{
+			name: m.display(key),
+			base: m.base(key),
+			file: file,
+		}
+	}
+	return &Snapshot{entries: entries}
+}
+
+// display returns the full path of key using the case each element was written in
+func (m *memory) display(key string) string {
+	parent := m.path.Dir(key)
+	if parent == key || parent == "." {
+		return key
+	}
+	return m.path.Join(m.display(parent), m.base(key))
+}
+
+// Restore implements SnapshotFS
+func (m *memory) Restore(snapshot *Snapshot) error {
+	if snapshot == nil {
+		return &iofs.PathError{Op: "restore", Err: iofs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changes := diffKeys(m.snapshot(), snapshot)
+
+	files := map[string]*fstest.MapFile{}
+	for key, entry := range snapshot.entries {
+		files[key] = entry.file
+	}
+	m.fs = cloneFiles(files)
+	m.names = make(map[string]string, len(snapshot.entries))
+	for key, entry := range snapshot.entries {
+		m.names[key] = entry.base
+	}
+
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeAdded:
+			m.notifyName(change.key, change.Path, OpCreate)
+		case ChangeRemoved:
+			m.notifyName(change.key, change.Path, OpRemove)
+		case ChangeModified:
+			m.notifyName(change.key, change.Path, OpWrite)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package fs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func newSnapshot(t *testing.T, plat platform.Platform, home string) (fs.FS, fs.SnapshotFS) {
+	fsys, path := newMemory(newOS(plat))
+	require.NoError(t, fsys.MkdirAll(path.Join(home, ".config"), 0755))
+	require.NoError(t, fsys.WriteFile(path.Join(home, ".profile"), []byte("profile"), 0644))
+	snapshots, ok := fsys.(fs.SnapshotFS)
+	require.True(t, ok)
+	return fsys, snapshots
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	fsys, snapshots := newSnapshot(t, platform.Linux, "/home/fake")
+	require.NoError(t, fsys.Link("/home/fake/.profile", "/home/fake/.profile.link"))
+	snapshot := snapshots.Snapshot()
+
+	require.NoError(t, fsys.WriteFile("/home/fake/.profile", []byte("changed"), 0644))
+	require.NoError(t, fsys.RemoveAll("/home/fake/.config"))
+	require.NoError(t, fsys.WriteFile("/home/fake/new.txt", []byte("new"), 0644))
+
+	require.NoError(t, snapshots.Restore(snapshot))
+
+	content, err := fsys.ReadFile("/home/fake/.profile")
+	require.NoError(t, err)
+	require.Equal(t, []byte("profile"), content)
+	ok, err := fsys.Exists("/home/fake/.config")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = fsys.Exists("/home/fake/new.txt")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// hard links still share data after a restore
+	require.NoError(t, fsys.WriteFile("/home/fake/.profile", []byte("shared"), 0644))
+	content, err = fsys.ReadFile("/home/fake/.profile.link")
+	require.NoError(t, err)
+	require.Equal(t, []byte("shared"), content)
+
+	// changes after a restore don't leak into the snapshot so it can be restored again
+	require.NoError(t, snapshots.Restore(snapshot))
+	content, err = fsys.ReadFile("/home/fake/.profile.link")
+	require.NoError(t, err)
+	require.Equal(t, []byte("profile"), content)
+
+	require.Error(t, snapshots.Restore(nil))
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	fsys, snapshots := newSnapshot(t, platform.Linux, "/home/fake")
+	require.NoError(t, fsys.WriteFile("/home/fake/remove.txt", []byte("remove"), 0644))
+	before := snapshots.Snapshot()
+
+	require.NoError(t, fsys.WriteFile("/home/fake/.config/app.yaml", []byte("app"), 0644))
+	require.NoError(t, fsys.WriteFile("/home/fake/.profile", []byte("changed"), 0644))
+	require.NoError(t, fsys.Chmod("/home/fake/.profile", 0600))
+	require.NoError(t, fsys.Remove("/home/fake/remove.txt"))
+	after := snapshots.Snapshot()
+
+	changes := fs.Diff(before, after)
+	require.Equal(t, []fs.Change{
+		{Kind: fs.ChangeAdded, Path: "/home/fake/.config/app.yaml"},
+		{Kind: fs.ChangeModified, Path: "/home/fake/.profile", Fields: []string{"mode", "data", "modtime"}},
+		{Kind: fs.ChangeRemoved, Path: "/home/fake/remove.txt"},
+	}, changes)
+	require.Equal(t, "added /home/fake/.config/app.yaml", changes[0].String())
+	require.Equal(t, "modified /home/fake/.profile (mode, data, modtime)", changes[1].String())
+
+	require.Empty(t, fs.Diff(after, after))
+}
+
+func TestSnapshotPreservesCase(t *testing.T) {
+	fsys, snapshots := newSnapshot(t, platform.Windows, `c:\Users\Fake`)
+	before := snapshots.Snapshot()
+	require.Contains(t, before.Paths(), `c:\Users\Fake\.profile`)
+
+	require.NoError(t, fsys.WriteFile(`C:\USERS\FAKE\.config\App.yaml`, []byte("app"), 0644))
+	changes := fs.Diff(before, snapshots.Snapshot())
+	require.Equal(t, []fs.Change{{Kind: fs.ChangeAdded, Path: `c:\Users\Fake\.config\App.yaml`}}, changes)
+
+	require.NoError(t, snapshots.Restore(before))
+	require.NoError(t, fsys.WriteFile(`c:\users\fake\.PROFILE`, []byte("changed"), 0644))
+	entries, err := fsys.ReadDir(`c:\users\fake`)
+	require.NoError(t, err)
+	require.Equal(t, ".profile", entries[1].Name())
+}
+
+func TestSnapshotRestoreNotifiesWatchers(t *testing.T) {
+	fsys, snapshots := newSnapshot(t, platform.Linux, "/home/fake")
+	snapshot := snapshots.Snapshot()
+	require.NoError(t, fsys.WriteFile("/home/fake/new.txt", []byte("new"), 0644))
+
+	watcher, err := fsys.Watch("/home/fake", false)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.NoError(t, snapshots.Restore(snapshot))
+	select {
+	case event := <-watcher.Events():
+		require.Equal(t, fs.Event{Name: "/home/fake/new.txt", Op: fs.OpRemove}, event)
+	case <-time.After(time.Second):
+		require.Fail(t, "expected a remove event")
+	}
+}
@@ -0,0 +1,221 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"reflect"
+	"syscall"
+
+	"github.com/patrickhuber/go-cross/filepath"
+)
+
+// Tree is a path in a file system along with the path provider used to join and parse paths in that file system
+type Tree struct {
+	FS   FS
+	Path filepath.Provider
+	Dir  string
+}
+
+// CopyPolicy determines how Copy and Move handle entries that already exist in the destination
+type CopyPolicy int
+
+const (
+	// CopyMerge merges existing directories and replaces existing files
+	CopyMerge CopyPolicy = iota
+	// CopyOverwrite replaces existing files and directories, removing destination only entries
+	CopyOverwrite
+	// CopySkip merges existing directories and leaves existing files untouched
+	CopySkip
+)
+
+type copyOptions struct {
+	policy CopyPolicy
+}
+
+type CopyOption func(*copyOptions)
+
+// WithCopyPolicy sets the policy used when an entry already exists in the destination
+func WithCopyPolicy(policy CopyPolicy) CopyOption {
+	return func(o *copyOptions) {
+		o.policy = policy
+	}
+}
+
+// Copy recursively copies src to dst preserving modes, modification times and symbolic links where the
+// destination supports them. Paths are joined with each tree's path provider so trees can be copied
+// between file systems with different path conventions.
+func Copy(src Tree, dst Tree, options ...CopyOption) error {
+	o := &copyOptions{
+		policy: CopyMerge,
+	}
+	for _, option := range options {
+		option(o)
+	}
+	if sameFS(src.FS, dst.FS) && within(src.Path, src.Dir, dst.Dir) {
+		return &os.LinkError{Op: "copy", Old: src.Dir, New: dst.Dir, Err: syscall.EINVAL}
+	}
+	return copyEntry(src, dst, src.Dir, dst.Dir, o, true)
+}
+
+// within returns true if name is dir or one of its descendants
+func within(path filepath.Provider, dir string, name string) bool {
+	rel, err := path.Rel(dir, name)
+	if err != nil {
+		return false
+	}
+	fp, err := path.Parse(rel)
+	if err != nil || fp.IsAbs() {
+		return false
+	}
+	return len(fp.Segments) == 0 || fp.Segments[0] != filepath.ParentDirectory
+}
+
+// Move recursively moves src to dst creating any missing parents of dst. When both trees share a file system and
+// the destination does not exist the move is a rename. Otherwise, or if the rename fails, for example because the
+// paths are on different devices, src is copied to dst and then removed.
+func Move(src Tree, dst Tree, options ...CopyOption) error {
+	if sameFS(src.FS, dst.FS) {
+		ok, err := dst.FS.Exists(dst.Dir)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := dst.FS.MkdirAll(dst.Path.Dir(dst.Dir), 0755); err != nil {
+				return err
+			}
+			if err := dst.FS.Rename(src.Dir, dst.Dir); err == nil {
+				return nil
+			}
+		}
+	}
+	if err := Copy(src, dst, options...); err != nil {
+		return err
+	}
+	return src.FS.RemoveAll(src.Dir)
+}
+
+func copyEntry(src Tree, dst Tree, srcPath string, dstPath string, o *copyOptions, root bool) error {
+	info, err := src.FS.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+
+	existing, err := dst.FS.Lstat(dstPath)
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		existing = nil
+	case err != nil:
+		return err
+	}
+
+	if existing != nil {
+		merge := info.IsDir() && existing.IsDir() && o.policy != CopyOverwrite
+		switch {
+		case merge:
+		case o.policy == CopySkip:
+			return nil
+		default:
+			if err := dst.FS.RemoveAll(dstPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case info.Mode()&iofs.ModeSymlink != 0:
+		return copySymlink(src, dst, srcPath, dstPath)
+	case info.IsDir():
+		return copyDir(src, dst, srcPath, dstPath, info, o, root)
+	}
+	return copyFile(src, dst, srcPath, dstPath, info)
+}
+
+func copyDir(src Tree, dst Tree, srcPath string, dstPath string, info iofs.FileInfo, o *copyOptions, root bool) error {
+	perm := info.Mode().Perm()
+
+	// the root creates any missing parents, children only need their own directory
+	var err error
+	if root {
+		err = dst.FS.MkdirAll(dstPath, perm|0700)
+	} else {
+		err = dst.FS.Mkdir(dstPath, perm|0700)
+	}
+	if err != nil && !errors.Is(err, iofs.ErrExist) {
+		return err
+	}
+
+	entries, err := src.FS.ReadDir(srcPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err = copyEntry(src, dst,
+			src.Path.Join(srcPath, entry.Name()),
+			dst.Path.Join(dstPath, entry.Name()),
+			o, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// set the mode and times after the children so they don't change the directory
+	if err = dst.FS.Chmod(dstPath, perm); err != nil {
+		return err
+	}
+	return dst.FS.Chtimes(dstPath, info.ModTime(), info.ModTime())
+}
+
+func copyFile(src Tree, dst Tree, srcPath string, dstPath string, info iofs.FileInfo) error {
+	in, err := src.FS.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	perm := info.Mode().Perm()
+	out, err := dst.FS.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm|0200)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	if err = dst.FS.Chmod(dstPath, perm); err != nil {
+		return err
+	}
+	return dst.FS.Chtimes(dstPath, info.ModTime(), info.ModTime())
+}
+
+func copySymlink(src Tree, dst Tree, srcPath string, dstPath string) error {
+	target, err := src.FS.Readlink(srcPath)
+	if err != nil {
+		return err
+	}
+
+	// translate the target to the destination's path conventions
+	fp, err := src.Path.Parse(target)
+	if err != nil {
+		return err
+	}
+	return dst.FS.Symlink(dst.Path.String(fp), dstPath)
+}
+
+// sameFS returns true if both file systems are the same instance. Every file system returned from New uses the disk
+// of the operating system so they are all the same
+func sameFS(first FS, second FS) bool {
+	if _, ok := first.(*osfs); ok {
+		_, ok = second.(*osfs)
+		return ok
+	}
+	if !reflect.TypeOf(first).Comparable() || !reflect.TypeOf(second).Comparable() {
+		return false
+	}
+	return first == second
+}
@@ -0,0 +1,268 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	gos "os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTree(t *testing.T, fsys fs.FS, path filepath.Provider, root string) time.Time {
+	modTime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, fsys.MkdirAll(path.Join(root, "sub"), 0755))
+	require.NoError(t, fsys.WriteFile(path.Join(root, "one.txt"), []byte("one"), 0644))
+	require.NoError(t, fsys.WriteFile(path.Join(root, "sub", "two.sh"), []byte("two"), 0755))
+	require.NoError(t, fsys.Symlink("one.txt", path.Join(root, "link.txt")))
+	require.NoError(t, fsys.Chtimes(path.Join(root, "one.txt"), modTime, modTime))
+	return modTime
+}
+
+func TestCopy(t *testing.T) {
+	type test struct {
+		name string
+		src  fs.Tree
+		dst  fs.Tree
+	}
+	linuxFS, linuxPath := newMemory(newOS(platform.Linux))
+	windowsFS, windowsPath := newMemory(newOS(platform.Windows))
+	otherFS, otherPath := newMemory(newOS(platform.Linux))
+	osPath := filepath.NewProviderFromOS(os.New())
+	tests := []test{
+		{
+			"linux_to_windows",
+			fs.Tree{FS: linuxFS, Path: linuxPath, Dir: "/gran/parent/child"},
+			fs.Tree{FS: windowsFS, Path: windowsPath, Dir: `c:\gran\parent\child`},
+		},
+		{
+			"memory_to_os",
+			fs.Tree{FS: otherFS, Path: otherPath, Dir: "/gran/parent/child"},
+			fs.Tree{FS: fs.New(), Path: osPath, Dir: osPath.Join(t.TempDir(), "child")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modTime := setupTree(t, test.src.FS, test.src.Path, test.src.Dir)
+
+			require.NoError(t, fs.Copy(test.src, test.dst))
+
+			content, err := test.dst.FS.ReadFile(test.dst.Path.Join(test.dst.Dir, "sub", "two.sh"))
+			require.NoError(t, err)
+			require.Equal(t, []byte("two"), content)
+
+			info, err := test.dst.FS.Stat(test.dst.Path.Join(test.dst.Dir, "sub", "two.sh"))
+			require.NoError(t, err)
+			require.Equal(t, iofs.FileMode(0755), info.Mode().Perm())
+
+			info, err = test.dst.FS.Stat(test.dst.Path.Join(test.dst.Dir, "one.txt"))
+			require.NoError(t, err)
+			require.True(t, modTime.Equal(info.ModTime()))
+
+			target, err := test.dst.FS.Readlink(test.dst.Path.Join(test.dst.Dir, "link.txt"))
+			require.NoError(t, err)
+			require.Equal(t, "one.txt", target)
+
+			content, err = test.dst.FS.ReadFile(test.dst.Path.Join(test.dst.Dir, "link.txt"))
+			require.NoError(t, err)
+			require.Equal(t, []byte("one"), content)
+		})
+	}
+}
+
+func TestCopyPolicy(t *testing.T) {
+	type test struct {
+		name     string
+		policy   fs.CopyPolicy
+		one      string
+		existing bool
+	}
+	tests := []test{
+		{"merge", fs.CopyMerge, "one", true},
+		{"overwrite", fs.CopyOverwrite, "one", false},
+		{"skip", fs.CopySkip, "existing", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys, path := newMemory(newOS(platform.Linux))
+			setupTree(t, fsys, path, "/src")
+
+			require.NoError(t, fsys.MkdirAll("/dst/sub", 0755))
+			require.NoError(t, fsys.WriteFile("/dst/one.txt", []byte("existing"), 0644))
+			require.NoError(t, fsys.WriteFile("/dst/sub/existing.txt", []byte("existing"), 0644))
+
+			src := fs.Tree{FS: fsys, Path: path, Dir: "/src"}
+			dst := fs.Tree{FS: fsys, Path: path, Dir: "/dst"}
+			require.NoError(t, fs.Copy(src, dst, fs.WithCopyPolicy(test.policy)))
+
+			content, err := fsys.ReadFile("/dst/one.txt")
+			require.NoError(t, err)
+			require.Equal(t, test.one, string(content))
+
+			ok, err := fsys.Exists("/dst/sub/existing.txt")
+			require.NoError(t, err)
+			require.Equal(t, test.existing, ok)
+
+			ok, err = fsys.Exists("/dst/sub/two.sh")
+			require.NoError(t, err)
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestCopyIntoItself(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Linux))
+	setupTree(t, fsys, path, "/a")
+
+	src := fs.Tree{FS: fsys, Path: path, Dir: "/a"}
+	for _, dir := range []string{"/a", "/a/b/c", "/a/sub/../b"} {
+		dst := fs.Tree{FS: fsys, Path: path, Dir: dir}
+		require.ErrorIs(t, fs.Copy(src, dst), syscall.EINVAL, dir)
+	}
+
+	ok, err := fsys.Exists("/a/b")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// a sibling with a common prefix is not inside the source
+	require.NoError(t, fs.Copy(src, fs.Tree{FS: fsys, Path: path, Dir: "/ab"}))
+}
+
+func TestMove(t *testing.T) {
+	t.Run("same_fs", func(t *testing.T) {
+		fsys, path := newMemory(newOS(platform.Linux))
+		setupTree(t, fsys, path, "/src")
+
+		src := fs.Tree{FS: fsys, Path: path, Dir: "/src"}
+		dst := fs.Tree{FS: fsys, Path: path, Dir: "/dst"}
+		require.NoError(t, fs.Move(src, dst))
+
+		ok, err := fsys.Exists("/src")
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		info, err := fsys.Stat("/dst")
+		require.NoError(t, err)
+		require.True(t, info.IsDir())
+
+		// the children move with the directory
+		ok, err = fsys.Exists("/src/sub/two.sh")
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		content, err := fsys.ReadFile("/dst/sub/two.sh")
+		require.NoError(t, err)
+		require.Equal(t, []byte("two"), content)
+
+		content, err = fsys.ReadFile("/dst/link.txt")
+		require.NoError(t, err)
+		require.Equal(t, []byte("one"), content)
+	})
+	t.Run("missing_parents", func(t *testing.T) {
+		fsys, path := newMemory(newOS(platform.Linux))
+		setupTree(t, fsys, path, "/src")
+
+		src := fs.Tree{FS: fsys, Path: path, Dir: "/src"}
+		dst := fs.Tree{FS: fsys, Path: path, Dir: "/missing/parent/dst"}
+		require.NoError(t, fs.Move(src, dst))
+
+		content, err := fsys.ReadFile("/missing/parent/dst/sub/two.sh")
+		require.NoError(t, err)
+		require.Equal(t, []byte("two"), content)
+	})
+	t.Run("rename_fails", func(t *testing.T) {
+		inner, path := newMemory(newOS(platform.Linux))
+		setupTree(t, inner, path, "/src")
+		fsys := fs.NewFault(inner, path, fs.Fault{Op: "Rename", Err: syscall.EXDEV})
+
+		// a rename across devices falls back to copying and removing the source
+		src := fs.Tree{FS: fsys, Path: path, Dir: "/src"}
+		dst := fs.Tree{FS: fsys, Path: path, Dir: "/dst"}
+		require.NoError(t, fs.Move(src, dst))
+
+		ok, err := fsys.Exists("/src")
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		content, err := fsys.ReadFile("/dst/sub/two.sh")
+		require.NoError(t, err)
+		require.Equal(t, []byte("two"), content)
+	})
+	t.Run("into_itself", func(t *testing.T) {
+		fsys, path := newMemory(newOS(platform.Linux))
+		setupTree(t, fsys, path, "/src")
+
+		src := fs.Tree{FS: fsys, Path: path, Dir: "/src"}
+		dst := fs.Tree{FS: fsys, Path: path, Dir: "/src/sub/dst"}
+		require.ErrorIs(t, fs.Move(src, dst), syscall.EINVAL)
+
+		content, err := fsys.ReadFile("/src/sub/two.sh")
+		require.NoError(t, err)
+		require.Equal(t, []byte("two"), content)
+	})
+	t.Run("across_fs", func(t *testing.T) {
+		linuxFS, linuxPath := newMemory(newOS(platform.Linux))
+		windowsFS, windowsPath := newMemory(newOS(platform.Windows))
+		setupTree(t, linuxFS, linuxPath, "/src")
+
+		src := fs.Tree{FS: linuxFS, Path: linuxPath, Dir: "/src"}
+		dst := fs.Tree{FS: windowsFS, Path: windowsPath, Dir: `c:\dst`}
+		require.NoError(t, fs.Move(src, dst))
+
+		ok, err := linuxFS.Exists("/src/one.txt")
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		content, err := windowsFS.ReadFile(`c:\dst\sub\two.sh`)
+		require.NoError(t, err)
+		require.Equal(t, []byte("two"), content)
+	})
+}
+
+func TestTreeWithSeparateOSFileSystems(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires elevated privileges on windows")
+	}
+	path := filepath.NewProviderFromOS(os.New())
+	newTrees := func(t *testing.T, dst string) (fs.Tree, fs.Tree) {
+		dir := t.TempDir()
+		src := fs.Tree{FS: fs.New(), Path: path, Dir: path.Join(dir, "src")}
+		setupTree(t, src.FS, path, src.Dir)
+		return src, fs.Tree{FS: fs.New(), Path: path, Dir: path.Join(dir, dst)}
+	}
+	t.Run("copy_into_itself", func(t *testing.T) {
+		src, dst := newTrees(t, path.Join("src", "sub", "dst"))
+		require.ErrorIs(t, fs.Copy(src, dst), syscall.EINVAL)
+
+		ok, err := src.FS.Exists(dst.Dir)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+	t.Run("move_into_itself", func(t *testing.T) {
+		src, dst := newTrees(t, path.Join("src", "sub", "dst"))
+		require.ErrorIs(t, fs.Move(src, dst), syscall.EINVAL)
+	})
+	t.Run("move_renames", func(t *testing.T) {
+		src, dst := newTrees(t, path.Join("parent", "dst"))
+		before, err := src.FS.Stat(path.Join(src.Dir, "one.txt"))
+		require.NoError(t, err)
+
+		require.NoError(t, fs.Move(src, dst))
+
+		// a rename keeps the file instead of copying it
+		after, err := dst.FS.Stat(path.Join(dst.Dir, "one.txt"))
+		require.NoError(t, err)
+		require.True(t, gos.SameFile(before, after))
+
+		ok, err := src.FS.Exists(src.Dir)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,189 @@
+package fs
+
+import (
+	iofs "io/fs"
+	gofilepath "path/filepath"
+	"syscall"
+)
+
+type walkOptions struct {
+	follow bool
+}
+
+type WalkOption func(*walkOptions)
+
+// WithFollowSymlinks makes Walk and WalkDir descend into symbolic links that point to directories. A link that
+// leads back to a directory already being walked is reported to the walk function with an ELOOP error
+func WithFollowSymlinks() WalkOption {
+	return func(o *walkOptions) {
+		o.follow = true
+	}
+}
+
+// WalkDir walks the file tree rooted at root.Dir calling fn for each file or directory including the root. Paths
+// are joined with the tree's path provider so the names passed to fn use the native separators of the file system.
+// Entries are visited in lexical order. fn can return iofs.SkipDir or iofs.SkipAll with the same meaning as in
+// io/fs.WalkDir. Symbolic links are not followed unless WithFollowSymlinks is given.
+func WalkDir(root Tree, fn iofs.WalkDirFunc, options ...WalkOption) error {
+	o := &walkOptions{}
+	for _, option := range options {
+		option(o)
+	}
+	w := &walker{tree: root, options: o, fn: fn}
+
+	var info iofs.FileInfo
+	var err error
+	if o.follow {
+		info, err = root.FS.Stat(root.Dir)
+	} else {
+		info, err = root.FS.Lstat(root.Dir)
+	}
+	if err != nil {
+		err = fn(root.Dir, nil, err)
+	} else {
+		err = w.walk(root.Dir, iofs.FileInfoToDirEntry(info), root.Path.Clean(root.Dir), nil)
+	}
+	if err == iofs.SkipDir || err == iofs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// Walk walks the file tree rooted at root.Dir calling fn with the file info of each file or directory including
+// the root. It behaves like WalkDir but calls Info on every entry
+func Walk(root Tree, fn gofilepath.WalkFunc, options ...WalkOption) error {
+	return WalkDir(root, func(name string, d iofs.DirEntry, err error) error {
+		if d == nil {
+			return fn(name, nil, err)
+		}
+		info, infoErr := d.Info()
+		if err == nil {
+			err = infoErr
+		}
+		return fn(name, info, err)
+	}, options...)
+}
+
+type walker struct {
+	tree    Tree
+	options *walkOptions
+	fn      iofs.WalkDirFunc
+}
+
+// walk visits name and its children. real is the path of name with symbolic links resolved and ancestors are the
+// real paths of the directories above name, both are used to detect symbolic link loops
+func (w *walker) walk(name string, d iofs.DirEntry, real string, ancestors []string) error {
+	if err := w.fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == iofs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := w.tree.FS.ReadDir(name)
+	if err != nil {
+		// second call to report the read error
+		err = w.fn(name, d, err)
+		if err != nil {
+			if err == iofs.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	ancestors = append(ancestors, real)
+	for _, entry := range entries {
+		child := w.tree.Path.Join(name, entry.Name())
+		childReal := w.tree.Path.Join(real, entry.Name())
+
+		if w.options.follow && entry.Type()&iofs.ModeSymlink != 0 {
+			err = w.follow(child, entry, childReal, ancestors)
+		} else {
+			err = w.walk(child, entry, childReal, ancestors)
+		}
+		if err != nil {
+			if err == iofs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// follow resolves the symbolic link at name and walks its target. Broken links are reported as links
+func (w *walker) follow(name string, entry iofs.DirEntry, real string, ancestors []string) error {
+	info, err := w.tree.FS.Stat(name)
+	if err != nil {
+		return w.walk(name, entry, real, ancestors)
+	}
+	followed := &followedEntry{DirEntry: iofs.FileInfoToDirEntry(info), name: entry.Name()}
+	if !info.IsDir() {
+		return w.walk(name, followed, real, ancestors)
+	}
+
+	target, err := w.resolve(name, real)
+	if err != nil {
+		return w.fn(name, entry, err)
+	}
+	cmp := w.tree.Path.Comparison()
+	for _, ancestor := range ancestors {
+		if cmp.Equal(ancestor, target) {
+			return w.fn(name, entry, &iofs.PathError{Op: "walk", Path: name, Err: syscall.ELOOP})
+		}
+	}
+	return w.walk(name, followed, target, ancestors)
+}
+
+// resolve follows the chain of symbolic links starting at name and returns the real path of the final target
+func (w *walker) resolve(name string, real string) (string, error) {
+	current := name
+	for links := 0; ; links++ {
+		if links > maxSymlinks {
+			return "", &iofs.PathError{Op: "walk", Path: name, Err: syscall.ELOOP}
+		}
+		target, err := w.tree.FS.Readlink(current)
+		if err != nil {
+			return "", err
+		}
+		fp, err := w.tree.Path.Parse(target)
+		if err != nil {
+			return "", &iofs.PathError{Op: "walk", Path: name, Err: err}
+		}
+		if fp.IsAbs() {
+			real = w.tree.Path.Clean(target)
+		} else {
+			real = w.tree.Path.Join(w.tree.Path.Dir(real), target)
+		}
+
+		info, err := w.tree.FS.Lstat(real)
+		if err != nil || info.Mode()&iofs.ModeSymlink == 0 {
+			return real, nil
+		}
+		current = real
+	}
+}
+
+// followedEntry is the entry of a followed symbolic link. It describes the target but keeps the name of the link
+type followedEntry struct {
+	iofs.DirEntry
+	name string
+}
+
+func (e *followedEntry) Name() string { return e.name }
+
+func (e *followedEntry) Info() (iofs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return &followedInfo{FileInfo: info, name: e.name}, nil
+}
+
+type followedInfo struct {
+	iofs.FileInfo
+	name string
+}
+
+func (i *followedInfo) Name() string { return i.name }
@@ -0,0 +1,187 @@
+package fs_test
+
+import (
+	iofs "io/fs"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func walkTrees(t *testing.T) map[string]fs.Tree {
+	linuxFS, linuxPath := newMemory(newOS(platform.Linux))
+	windowsFS, windowsPath := newMemory(newOS(platform.Windows))
+	osPath := filepath.NewProviderFromOS(os.New())
+	return map[string]fs.Tree{
+		"linux":   {FS: linuxFS, Path: linuxPath, Dir: "/gran/parent/child"},
+		"windows": {FS: windowsFS, Path: windowsPath, Dir: `c:\gran\parent\child`},
+		"os":      {FS: fs.New(), Path: osPath, Dir: osPath.Join(t.TempDir(), "child")},
+	}
+}
+
+func setupWalk(t *testing.T, tree fs.Tree) {
+	path := tree.Path
+	require.NoError(t, tree.FS.MkdirAll(path.Join(tree.Dir, "a", "deep"), 0755))
+	require.NoError(t, tree.FS.MkdirAll(path.Join(tree.Dir, "b"), 0755))
+	require.NoError(t, tree.FS.WriteFile(path.Join(tree.Dir, "a", "deep", "file.txt"), []byte("deep"), 0644))
+	require.NoError(t, tree.FS.WriteFile(path.Join(tree.Dir, "b", "file.txt"), []byte("b"), 0644))
+	require.NoError(t, tree.FS.WriteFile(path.Join(tree.Dir, "c.txt"), []byte("c"), 0644))
+}
+
+func walkNames(t *testing.T, tree fs.Tree, fn func(name string, d iofs.DirEntry) error, options ...fs.WalkOption) []string {
+	var names []string
+	err := fs.WalkDir(tree, func(name string, d iofs.DirEntry, err error) error {
+		require.NoError(t, err)
+		names = append(names, name)
+		if fn == nil {
+			return nil
+		}
+		return fn(name, d)
+	}, options...)
+	require.NoError(t, err)
+	return names
+}
+
+func TestWalkDir(t *testing.T) {
+	for name, tree := range walkTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			setupWalk(t, tree)
+			path := tree.Path
+
+			names := walkNames(t, tree, nil)
+			require.Equal(t, []string{
+				tree.Dir,
+				path.Join(tree.Dir, "a"),
+				path.Join(tree.Dir, "a", "deep"),
+				path.Join(tree.Dir, "a", "deep", "file.txt"),
+				path.Join(tree.Dir, "b"),
+				path.Join(tree.Dir, "b", "file.txt"),
+				path.Join(tree.Dir, "c.txt"),
+			}, names)
+		})
+	}
+}
+
+func TestWalkDirSkip(t *testing.T) {
+	for name, tree := range walkTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			setupWalk(t, tree)
+			path := tree.Path
+
+			// skipping a directory skips its children
+			names := walkNames(t, tree, func(name string, d iofs.DirEntry) error {
+				if d.IsDir() && d.Name() == "a" {
+					return iofs.SkipDir
+				}
+				return nil
+			})
+			require.Equal(t, []string{
+				tree.Dir,
+				path.Join(tree.Dir, "a"),
+				path.Join(tree.Dir, "b"),
+				path.Join(tree.Dir, "b", "file.txt"),
+				path.Join(tree.Dir, "c.txt"),
+			}, names)
+
+			// skipping from a file skips the rest of its directory
+			names = walkNames(t, tree, func(name string, d iofs.DirEntry) error {
+				if name == path.Join(tree.Dir, "b", "file.txt") {
+					return iofs.SkipDir
+				}
+				return nil
+			})
+			require.Equal(t, path.Join(tree.Dir, "c.txt"), names[len(names)-1])
+
+			// skip all stops the walk without an error
+			names = walkNames(t, tree, func(name string, d iofs.DirEntry) error {
+				if d.Name() == "deep" {
+					return iofs.SkipAll
+				}
+				return nil
+			})
+			require.Equal(t, path.Join(tree.Dir, "a", "deep"), names[len(names)-1])
+		})
+	}
+}
+
+func TestWalkDirMissingRoot(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Linux))
+	tree := fs.Tree{FS: fsys, Path: path, Dir: "/missing"}
+
+	called := false
+	err := fs.WalkDir(tree, func(name string, d iofs.DirEntry, err error) error {
+		called = true
+		require.Nil(t, d)
+		return err
+	})
+	require.True(t, called)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func TestWalkDirSymlinks(t *testing.T) {
+	for name, tree := range walkTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			if name == "os" && runtime.GOOS == "windows" {
+				t.Skip("creating symbolic links requires elevated privileges on windows")
+			}
+			setupWalk(t, tree)
+			path := tree.Path
+			require.NoError(t, tree.FS.Symlink(path.Join(tree.Dir, "b"), path.Join(tree.Dir, "a", "to_b")))
+			require.NoError(t, tree.FS.Symlink("..", path.Join(tree.Dir, "b", "loop")))
+
+			// links are reported but not followed by default
+			names := walkNames(t, tree, nil)
+			require.Contains(t, names, path.Join(tree.Dir, "a", "to_b"))
+			require.NotContains(t, names, path.Join(tree.Dir, "a", "to_b", "file.txt"))
+
+			// followed links keep their name and report the target's type, loops are reported instead of followed
+			var loops []string
+			names = nil
+			err := fs.WalkDir(tree, func(name string, d iofs.DirEntry, err error) error {
+				if err != nil {
+					require.ErrorIs(t, err, syscall.ELOOP)
+					loops = append(loops, name)
+					return nil
+				}
+				if name == path.Join(tree.Dir, "a", "to_b") {
+					require.Equal(t, "to_b", d.Name())
+					require.True(t, d.IsDir())
+				}
+				names = append(names, name)
+				return nil
+			}, fs.WithFollowSymlinks())
+			require.NoError(t, err)
+			require.Contains(t, names, path.Join(tree.Dir, "a", "to_b", "file.txt"))
+			require.Equal(t, []string{
+				path.Join(tree.Dir, "a", "to_b", "loop"),
+				path.Join(tree.Dir, "b", "loop"),
+			}, loops)
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Windows))
+	tree := fs.Tree{FS: fsys, Path: path, Dir: `c:\gran\parent\child`}
+	setupWalk(t, tree)
+
+	sizes := map[string]int64{}
+	err := fs.Walk(tree, func(name string, info iofs.FileInfo, err error) error {
+		require.NoError(t, err)
+		if !info.IsDir() {
+			sizes[name] = info.Size()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{
+		`c:\gran\parent\child\a\deep\file.txt`: 4,
+		`c:\gran\parent\child\b\file.txt`:      1,
+		`c:\gran\parent\child\c.txt`:           1,
+	}, sizes)
+}
@@ -0,0 +1,173 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"strings"
+)
+
+// Op describes a set of file operations
+type Op uint32
+
+const (
+	// OpCreate is emitted when a file or directory is created
+	OpCreate Op = 1 << iota
+	// OpWrite is emitted when a file is written or truncated
+	OpWrite
+	// OpRemove is emitted when a file or directory is removed
+	OpRemove
+	// OpRename is emitted for the old name when a file or directory is renamed. The new name emits OpCreate
+	OpRename
+	// OpChmod is emitted when the attributes of a file change
+	OpChmod
+)
+
+// ErrEventOverflow is sent on the Errors channel when events were dropped because the Events channel was full
+var ErrEventOverflow = errors.New("fs: event queue overflow")
+
+// watchBufferSize is the number of events buffered before events are dropped
+const watchBufferSize = 128
+
+// String returns the names of the operations in the set separated by |
+func (op Op) String() string {
+	var names []string
+	for _, item := range []struct {
+		op   Op
+		name string
+	}{
+		{OpCreate, "CREATE"},
+		{OpWrite, "WRITE"},
+		{OpRemove, "REMOVE"},
+		{OpRename, "RENAME"},
+		{OpChmod, "CHMOD"},
+	} {
+		if op&item.op != 0 {
+			names = append(names, item.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Has returns true if the set contains the operation
+func (op Op) Has(other Op) bool {
+	return op&other != 0
+}
+
+// Event describes a change to a path in the file system
+type Event struct {
+	// Name is the path of the file that changed
+	Name string
+	// Op is the operation that caused the event
+	Op Op
+}
+
+// Watcher delivers change events for a watched path
+type Watcher interface {
+	// Events returns the channel of change events. The channel is closed when the watcher is closed
+	Events() <-chan Event
+	// Errors returns the channel of errors encountered while watching. The channel is closed when the watcher is closed
+	Errors() <-chan error
+	// Close stops watching and closes the channels
+	Close() error
+}
+
+type memoryWatcher struct {
+	memory    *memory
+	key       string
+	recursive bool
+	events    chan Event
+	errors    chan error
+}
+
+// Watch implements WatchFS
+func (m *memory) Watch(name string, recursive bool) (Watcher, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op := "watch"
+	key, file, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+	if file == nil {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrNotExist}
+	}
+
+	w := &memoryWatcher{
+		memory:    m,
+		key:       key,
+		recursive: recursive,
+		events:    make(chan Event, watchBufferSize),
+		errors:    make(chan error, 1),
+	}
+	m.watchers = append(m.watchers, w)
+	return w, nil
+}
+
+// notify sends the event to all watchers of the key. Events are delivered before the operation
+// returns so watcher driven code can be tested deterministically. The caller must hold the lock.
+func (m *memory) notify(key string, op Op) {
+	m.notifyName(key, m.display(key), op)
+}
+
+// notifyName sends the event to all watchers of the key using name, the path in the case it was written in, as
+// the event name. Callers that remove the key pass the name they captured before removing it
+func (m *memory) notifyName(key string, name string, op Op) {
+	for _, w := range m.watchers {
+		if !w.matches(key) {
+			continue
+		}
+		select {
+		case w.events <- Event{Name: name, Op: op}:
+		default:
+			select {
+			case w.errors <- ErrEventOverflow:
+			default:
+			}
+		}
+	}
+}
+
+// matches returns true if the key is the watched path, a child of the watched path or, for recursive watchers, a descendant
+func (w *memoryWatcher) matches(key string) bool {
+	if key == w.key {
+		return true
+	}
+	path := w.memory.path
+	if path.Dir(key) == w.key {
+		return true
+	}
+	if !w.recursive {
+		return false
+	}
+	prefix := w.key
+	if !strings.HasSuffix(prefix, string(path.Separator())) {
+		prefix += string(path.Separator())
+	}
+	return strings.HasPrefix(key, prefix)
+}
+
+func (w *memoryWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *memoryWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *memoryWatcher) Close() error {
+	m := w.memory
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, other := range m.watchers {
+		if other != w {
+			continue
+		}
+		m.watchers = append(m.watchers[:i], m.watchers[i+1:]...)
+		close(w.events)
+		close(w.errors)
+		break
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+//go:build linux
+
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE |
+	syscall.IN_MODIFY |
+	syscall.IN_ATTRIB |
+	syscall.IN_DELETE |
+	syscall.IN_DELETE_SELF |
+	syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO |
+	syscall.IN_MOVE_SELF
+
+type inotifyWatcher struct {
+	fd        int
+	file      *os.File
+	recursive bool
+	events    chan Event
+	errors    chan error
+	done      chan struct{}
+	once      sync.Once
+
+	mu    sync.Mutex
+	paths map[int32]string
+}
+
+func watch(name string, recursive bool) (Watcher, error) {
+	op := "watch"
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	// a non blocking descriptor uses the runtime poller so Close unblocks pending reads
+	w := &inotifyWatcher{
+		fd:        fd,
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		recursive: recursive,
+		events:    make(chan Event, watchBufferSize),
+		errors:    make(chan error, 1),
+		done:      make(chan struct{}),
+		paths:     map[int32]string{},
+	}
+
+	if recursive && info.IsDir() {
+		err = w.addTree(name)
+	} else {
+		err = w.add(name)
+	}
+	if err != nil {
+		w.file.Close()
+		return nil, &iofs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *inotifyWatcher) add(name string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, name, inotifyMask)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paths[int32(wd)] = name
+	return nil
+}
+
+func (w *inotifyWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return w.add(path)
+	})
+}
+
+func (w *inotifyWatcher) run() {
+	defer close(w.events)
+	defer close(w.errors)
+
+	var buf [syscall.SizeofInotifyEvent * 4096]byte
+	for {
+		n, err := w.file.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.sendError(err)
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(raw.Len)
+			name := strings.TrimRight(string(buf[nameStart:nameEnd]), "\x00")
+			offset = nameEnd
+
+			if !w.handle(raw.Wd, raw.Mask, name) {
+				return
+			}
+		}
+	}
+}
+
+// handle translates the raw inotify event and returns false if the watcher is closed
+func (w *inotifyWatcher) handle(wd int32, mask uint32, name string) bool {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return w.sendError(ErrEventOverflow)
+	}
+
+	w.mu.Lock()
+	path, ok := w.paths[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.paths, wd)
+	}
+	w.mu.Unlock()
+	if !ok {
+		return true
+	}
+	if name != "" {
+		path = filepath.Join(path, name)
+	}
+
+	// watch directories created below a recursive watch
+	if w.recursive && mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		if err := w.addTree(path); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+			if !w.sendError(err) {
+				return false
+			}
+		}
+	}
+
+	var op Op
+	if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		op |= OpCreate
+	}
+	if mask&syscall.IN_MODIFY != 0 {
+		op |= OpWrite
+	}
+	if mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0 {
+		op |= OpRemove
+	}
+	if mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0 {
+		op |= OpRename
+	}
+	if mask&syscall.IN_ATTRIB != 0 {
+		op |= OpChmod
+	}
+	if op == 0 {
+		return true
+	}
+
+	select {
+	case w.events <- Event{Name: path, Op: op}:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *inotifyWatcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *inotifyWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *inotifyWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *inotifyWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+)
+
+func watch(name string, recursive bool) (Watcher, error) {
+	return nil, &iofs.PathError{Op: "watch", Path: name, Err: errors.ErrUnsupported}
+}
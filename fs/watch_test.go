@@ -0,0 +1,159 @@
+package fs_test
+
+import (
+	gos "os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/patrickhuber/go-cross/filepath"
+	"github.com/patrickhuber/go-cross/fs"
+	"github.com/patrickhuber/go-cross/os"
+	"github.com/patrickhuber/go-cross/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(w fs.Watcher) []fs.Event {
+	var events []fs.Event
+	for {
+		select {
+		case event := <-w.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestMemoryWatch(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Linux))
+	require.NoError(t, fsys.MkdirAll("/gran/parent/child", 0775))
+
+	w, err := fsys.Watch("/gran/parent", false)
+	require.NoError(t, err)
+	defer w.Close()
+
+	one := path.Join("/gran/parent", "one.txt")
+	two := path.Join("/gran/parent", "two.txt")
+
+	require.NoError(t, fsys.WriteFile(one, []byte("one"), 0644))
+	require.Equal(t, []fs.Event{
+		{Name: one, Op: fs.OpCreate},
+		{Name: one, Op: fs.OpWrite},
+	}, drain(w))
+
+	// rewriting an existing file is a single write
+	require.NoError(t, fsys.WriteFile(one, []byte("uno"), 0644))
+	require.Equal(t, []fs.Event{{Name: one, Op: fs.OpWrite}}, drain(w))
+
+	require.NoError(t, fsys.Chmod(one, 0600))
+	require.Equal(t, []fs.Event{{Name: one, Op: fs.OpChmod}}, drain(w))
+
+	require.NoError(t, fsys.Rename(one, two))
+	require.Equal(t, []fs.Event{
+		{Name: one, Op: fs.OpRename},
+		{Name: two, Op: fs.OpCreate},
+	}, drain(w))
+
+	f, err := fsys.OpenFile(two, gos.O_WRONLY|gos.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("more"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, []fs.Event{{Name: two, Op: fs.OpWrite}}, drain(w))
+
+	require.NoError(t, fsys.Remove(two))
+	require.Equal(t, []fs.Event{{Name: two, Op: fs.OpRemove}}, drain(w))
+
+	// grandchildren are not reported without recursion
+	require.NoError(t, fsys.WriteFile("/gran/parent/child/three.txt", []byte("three"), 0644))
+	require.Empty(t, drain(w))
+
+	// changes outside the watched directory are not reported
+	require.NoError(t, fsys.WriteFile("/gran/four.txt", []byte("four"), 0644))
+	require.Empty(t, drain(w))
+
+	require.NoError(t, w.Close())
+	_, ok := <-w.Events()
+	require.False(t, ok)
+}
+
+func TestMemoryWatchRecursive(t *testing.T) {
+	fsys, path := newMemory(newOS(platform.Windows))
+	require.NoError(t, fsys.MkdirAll(`c:\gran\parent\child`, 0775))
+
+	w, err := fsys.Watch(`c:\gran`, true)
+	require.NoError(t, err)
+	defer w.Close()
+
+	name := path.Join(`c:\gran\parent\child`, "three.txt")
+	require.NoError(t, fsys.WriteFile(name, []byte("three"), 0644))
+	require.Equal(t, []fs.Event{
+		{Name: name, Op: fs.OpCreate},
+		{Name: name, Op: fs.OpWrite},
+	}, drain(w))
+
+	require.NoError(t, fsys.Mkdir(`c:\gran\other`, 0775))
+	require.Equal(t, []fs.Event{{Name: `c:\gran\other`, Op: fs.OpCreate}}, drain(w))
+
+	// events use the case the path was written in even when it is accessed with a different case
+	require.NoError(t, fsys.MkdirAll(`c:\gran\Work`, 0775))
+	require.NoError(t, fsys.WriteFile(`c:\gran\Work\Foo.TXT`, []byte("foo"), 0644))
+	require.NoError(t, fsys.Chmod(`C:\GRAN\WORK\FOO.TXT`, 0600))
+	require.NoError(t, fsys.Rename(`c:\gran\work\foo.txt`, `c:\gran\work\Bar.txt`))
+	require.NoError(t, fsys.Remove(`C:\Gran\Work\BAR.TXT`))
+	require.Equal(t, []fs.Event{
+		{Name: `c:\gran\Work`, Op: fs.OpCreate},
+		{Name: `c:\gran\Work\Foo.TXT`, Op: fs.OpCreate},
+		{Name: `c:\gran\Work\Foo.TXT`, Op: fs.OpWrite},
+		{Name: `c:\gran\Work\Foo.TXT`, Op: fs.OpChmod},
+		{Name: `c:\gran\Work\Foo.TXT`, Op: fs.OpRename},
+		{Name: `c:\gran\Work\Bar.txt`, Op: fs.OpCreate},
+		{Name: `c:\gran\Work\Bar.txt`, Op: fs.OpRemove},
+	}, drain(w))
+}
+
+func TestMemoryWatchNotExist(t *testing.T) {
+	fsys, _ := newMemory(newOS(platform.Linux))
+	_, err := fsys.Watch("/missing", false)
+	require.Error(t, err)
+}
+
+func TestOSWatch(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("watch is only supported on linux")
+	}
+	fsys := fs.New()
+	path := filepath.NewProviderFromOS(os.New())
+	dir := t.TempDir()
+	require.NoError(t, fsys.Mkdir(path.Join(dir, "child"), 0775))
+
+	w, err := fsys.Watch(dir, true)
+	require.NoError(t, err)
+	defer w.Close()
+
+	next := func() fs.Event {
+		select {
+		case event := <-w.Events():
+			return event
+		case err := <-w.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+		return fs.Event{}
+	}
+
+	name := path.Join(dir, "child", "one.txt")
+	require.NoError(t, fsys.WriteFile(name, []byte("one"), 0644))
+	require.Equal(t, fs.Event{Name: name, Op: fs.OpCreate}, next())
+
+	require.NoError(t, fsys.Remove(name))
+	for {
+		event := next()
+		if event.Op.Has(fs.OpRemove) {
+			require.Equal(t, name, event.Name)
+			break
+		}
+	}
+}
@@ -10,9 +10,11 @@ import (
 const (
 	FakeWindowsWorkingDirectory = "c:\\working"
 	FakeWindowsHomeDirectory    = "c:\\users\\fake"
+	FakeWindowsTempDirectory    = "c:\\users\\fake\\AppData\\Local\\Temp"
 
 	FakeUnixWorkingDirectory = "/working"
 	FakeUnixHomeDirectory    = "/home/fake"
+	FakeUnixTempDirectory    = "/tmp"
 )
 
 type memory struct {
@@ -21,6 +23,7 @@ type memory struct {
 
 	architecture  arch.Arch
 	homeDirectory string
+	tempDirectory string
 }
 
 type MemoryOption func(*memory)
@@ -31,6 +34,12 @@ func WithHomeDirectory(homeDirectory string) MemoryOption {
 	}
 }
 
+func WithTempDirectory(tempDirectory string) MemoryOption {
+	return func(o *memory) {
+		o.tempDirectory = tempDirectory
+	}
+}
+
 func WithArchitecture(architecture arch.Arch) MemoryOption {
 	return func(o *memory) {
 		o.architecture = architecture
@@ -75,6 +84,13 @@ func NewMemory(options ...MemoryOption) OS {
 			o.homeDirectory = FakeUnixHomeDirectory
 		}
 	}
+	if o.tempDirectory == "" {
+		if platform.IsWindows(o.platform) {
+			o.tempDirectory = FakeWindowsTempDirectory
+		} else {
+			o.tempDirectory = FakeUnixTempDirectory
+		}
+	}
 	return o
 }
 
@@ -98,3 +114,7 @@ func (o *memory) ChangeDirectory(dir string) error {
 	o.workingDirectory = dir
 	return nil
 }
+
+func (o *memory) TempDir() string {
+	return o.tempDirectory
+}
@@ -135,3 +135,22 @@ func TestWorkingDirectory(t *testing.T) {
 		require.Equal(t, test.expected, workingDirectory, "test [%d] failed", i)
 	}
 }
+
+func TestTempDir(t *testing.T) {
+	type test struct {
+		expected string
+		o        os.OS
+	}
+	const (
+		OtherTemp = "/var/tmp"
+	)
+	tests := []test{
+		{expected: os.FakeUnixTempDirectory, o: os.NewMemory(os.WithPlatform(platform.Darwin))},
+		{expected: os.FakeUnixTempDirectory, o: os.NewMemory(os.WithPlatform(platform.Linux))},
+		{expected: os.FakeWindowsTempDirectory, o: os.NewMemory(os.WithPlatform(platform.Windows))},
+		{expected: OtherTemp, o: os.NewMemory(os.WithTempDirectory(OtherTemp))},
+	}
+	for i, test := range tests {
+		require.Equal(t, test.expected, test.o.TempDir(), "test [%d] failed", i)
+	}
+}
@@ -15,6 +15,7 @@ type OS interface {
 	Platform() platform.Platform
 	Architecture() arch.Arch
 	Home() (string, error)
+	TempDir() string
 }
 
 type realOS struct {
@@ -43,3 +44,7 @@ func (o *realOS) Home() (string, error) {
 func (o *realOS) ChangeDirectory(dir string) error {
 	return os.Chdir(dir)
 }
+
+func (o *realOS) TempDir() string {
+	return os.TempDir()
+}
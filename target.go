@@ -23,7 +23,7 @@ type Target interface {
 func NewTest(p platform.Platform, a arch.Arch, args ...string) Target {
 	os := os.NewMemory(os.WithPlatform(p))
 	path := filepath.NewProviderFromOS(os)
-	fs := fs.NewMemory(path)
+	fs := fs.NewMemory(path, fs.WithTempDir(os.TempDir()))
 
 	wd, _ := os.WorkingDirectory()
 	_ = fs.MkdirAll(wd, 0755)
@@ -31,6 +31,8 @@ func NewTest(p platform.Platform, a arch.Arch, args ...string) Target {
 	home, _ := os.Home()
 	_ = fs.MkdirAll(home, 0755)
 
+	_ = fs.MkdirAll(os.TempDir(), 0755)
+
 	return &target{
 		os:           os,
 		platform:     p,
@@ -35,9 +35,9 @@ func TestInitializesDirectories(t *testing.T) {
 		paths    []string
 	}
 	tests := []test{
-		{platform.Windows, []string{os.FakeWindowsHomeDirectory, os.FakeWindowsWorkingDirectory}},
-		{platform.Linux, []string{os.FakeUnixHomeDirectory, os.FakeUnixWorkingDirectory}},
-		{platform.Darwin, []string{os.FakeUnixHomeDirectory, os.FakeUnixWorkingDirectory}},
+		{platform.Windows, []string{os.FakeWindowsHomeDirectory, os.FakeWindowsWorkingDirectory, os.FakeWindowsTempDirectory}},
+		{platform.Linux, []string{os.FakeUnixHomeDirectory, os.FakeUnixWorkingDirectory, os.FakeUnixTempDirectory}},
+		{platform.Darwin, []string{os.FakeUnixHomeDirectory, os.FakeUnixWorkingDirectory, os.FakeUnixTempDirectory}},
 	}
 	for _, test := range tests {
 		t.Run(test.platform.String(), func(t *testing.T) {
@@ -51,3 +51,24 @@ func TestInitializesDirectories(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateTempUsesTempDirectory(t *testing.T) {
+	type test struct {
+		platform platform.Platform
+		dir      string
+	}
+	tests := []test{
+		{platform.Windows, os.FakeWindowsTempDirectory},
+		{platform.Linux, os.FakeUnixTempDirectory},
+		{platform.Darwin, os.FakeUnixTempDirectory},
+	}
+	for _, test := range tests {
+		t.Run(test.platform.String(), func(t *testing.T) {
+			target := cross.NewTest(test.platform, arch.AMD64)
+			f, err := target.FS().CreateTemp("", "test-*.txt")
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			require.Equal(t, test.dir, target.Path().Dir(f.Name()))
+		})
+	}
+}